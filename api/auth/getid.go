@@ -23,18 +23,17 @@ import (
 	"fmt"
 	"net/http"
 
-	"github.com/flatgrassdotnet/cloudbox/db"
 	"github.com/flatgrassdotnet/cloudbox/utils"
 )
 
-func GetID(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetID(w http.ResponseWriter, r *http.Request) {
 	ticket, err := base64.StdEncoding.DecodeString(r.URL.Query().Get("ticket"))
 	if err != nil {
 		utils.WriteError(w, r, fmt.Sprintf("failed to decode ticket value: %s", err))
 		return
 	}
 
-	steamid, err := db.FetchSteamIDFromTicket(ticket)
+	steamid, err := h.Sessions.FetchSteamIDFromTicket(ticket)
 	if err != nil {
 		utils.WriteError(w, r, fmt.Sprintf("failed to fetch steamid from ticket: %s", err))
 		return
@@ -22,23 +22,20 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"strconv"
 	"strings"
 
-	"github.com/flatgrassdotnet/cloudbox/db"
 	"github.com/flatgrassdotnet/cloudbox/utils"
 )
 
-func FastDL(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) FastDL(w http.ResponseWriter, r *http.Request) {
 	file := r.URL.Query().Get("file")
 	if file == "" {
 		utils.WriteError(w, r, "missing file value")
 		return
 	}
 
-	id, err := db.FetchFileInfoFromPath(strings.TrimPrefix(file, "/"))
+	id, err := h.Packages.FetchFileInfoFromPath(strings.TrimPrefix(file, "/"))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			http.Error(w, "file not found", http.StatusNotFound)
@@ -49,14 +46,5 @@ func FastDL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	f, err := db.GetContentFile(id)
-	if err != nil {
-		utils.WriteError(w, r, fmt.Sprintf("failed to open content file: %s", err))
-		return
-	}
-
-	defer f.Body.Close()
-
-	w.Header().Set("Content-Length", strconv.Itoa(int(*f.ContentLength)))
-	io.Copy(w, f.Body)
+	h.serveContentFile(w, r, id)
 }
@@ -24,25 +24,46 @@ import (
 	"net/http"
 	"strconv"
 
-	"github.com/flatgrassdotnet/cloudbox/db"
 	"github.com/flatgrassdotnet/cloudbox/utils"
 )
 
-func Get(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(r.URL.Query().Get("id"))
 	if err != nil {
 		utils.WriteError(w, r, fmt.Sprintf("failed to parse id value: %s", err))
 		return
 	}
 
-	o, err := db.GetContentFile(id)
+	h.serveContentFile(w, r, id)
+}
+
+// serveContentFile sends a content file with its sha256 as etag
+// http.ServeContent takes care of Range, If-Range, If-None-Match and If-Modified-Since
+// files nothing has hashed yet go out without an etag and are hashed on the way instead of read twice
+func (h *Handler) serveContentFile(w http.ResponseWriter, r *http.Request, id int) {
+	info, err := h.Files.StatContentFile(id)
+	if err != nil {
+		utils.WriteError(w, r, fmt.Sprintf("failed to stat content file: %s", err))
+		return
+	}
+
+	_, sum, ok, err := h.Files.FetchFileSums(id)
 	if err != nil {
-		utils.WriteError(w, r, fmt.Sprintf("failed to open content file for reading: %s", err))
+		utils.WriteError(w, r, fmt.Sprintf("failed to fetch content file sums: %s", err))
 		return
 	}
 
-	defer o.Body.Close()
+	body := h.Files.OpenContentFile(r.Context(), info)
+	defer body.Close()
+
+	var content io.ReadSeeker = body
+	if ok {
+		w.Header().Set("ETag", fmt.Sprintf("\"%s\"", sum))
+	} else {
+		content = newSummingReader(body, info.Size, h.storeSums(id))
+	}
 
-	w.Header().Set("Content-Length", strconv.Itoa(int(*o.ContentLength)))
-	io.Copy(w, o.Body)
+	// set so ServeContent doesn't read the start of the file to sniff it
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", info.ModTime, content)
 }
@@ -19,48 +19,95 @@
 package content
 
 import (
-	"archive/zip"
-	"bytes"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"strconv"
 
-	"github.com/flatgrassdotnet/cloudbox/db"
+	"github.com/flatgrassdotnet/cloudbox/storage"
 	"github.com/flatgrassdotnet/cloudbox/utils"
 )
 
-func GetZIP(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetZIP(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(r.URL.Query().Get("id"))
 	if err != nil {
 		utils.WriteError(w, r, fmt.Sprintf("failed to parse id value: %s", err))
 		return
 	}
 
-	o, err := db.GetContentFile(id)
+	info, err := h.Files.StatContentFile(id)
 	if err != nil {
-		utils.WriteError(w, r, fmt.Sprintf("failed to open content file for reading: %s", err))
+		utils.WriteError(w, r, fmt.Sprintf("failed to stat content file: %s", err))
 		return
 	}
 
-	defer o.Body.Close()
+	crc, sum, ok, err := h.Files.FetchFileSums(id)
+	if err != nil {
+		utils.WriteError(w, r, fmt.Sprintf("failed to fetch content file sums: %s", err))
+		return
+	}
+
+	// reading the file once for its crc and again to send it would double the egress
+	if !ok {
+		h.streamZIP(w, r, id, info)
+		return
+	}
+
+	head, tail, err := zipHeaders("file", info.Size, crc, info.ModTime, false)
+	if err != nil {
+		utils.WriteError(w, r, fmt.Sprintf("failed to create zip headers: %s", err))
+		return
+	}
 
-	// GM12 won't show download progress without Content-Length
-	buf := new(bytes.Buffer)
+	body := h.Files.OpenContentFile(r.Context(), info)
+	defer body.Close()
 
-	zw := zip.NewWriter(buf)
+	// GM12 won't show download progress without Content-Length, ServeContent always sets it
+	// the zip is derived from the file alone (objects never change in place), so its etag is too
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("ETag", fmt.Sprintf("\"%s-zip\"", sum))
+	http.ServeContent(w, r, "", info.ModTime, newZipReader(head, body, info.Size, tail))
+}
 
-	file, err := zw.Create("file")
+// streamZIP sends a file nothing has hashed yet in one pass, with its crc in a data descriptor
+// the tail isn't known until the data has been read, so there are no ranges or etag this time
+// the sums are stored on the way so later requests take the ServeContent path
+func (h *Handler) streamZIP(w http.ResponseWriter, r *http.Request, id int, info storage.ObjectInfo) {
+	head, _, err := zipHeaders("file", info.Size, 0, info.ModTime, true)
 	if err != nil {
-		utils.WriteError(w, r, fmt.Sprintf("failed to open zip file for writing: %s", err))
+		utils.WriteError(w, r, fmt.Sprintf("failed to create zip headers: %s", err))
 		return
 	}
 
-	io.Copy(file, o.Body)
+	body := h.Files.OpenContentFile(r.Context(), info)
+	defer body.Close()
 
-	zw.Close()
+	var crc uint32
+	var summed bool
+	store := h.storeSums(id)
+	data := newSummingReader(body, info.Size, func(c uint32, sum string) {
+		crc, summed = c, true
+		store(c, sum)
+	})
 
 	w.Header().Set("Content-Type", "application/zip")
-	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
-	io.Copy(w, buf)
+	w.Header().Set("Content-Length", strconv.FormatInt(zipSize("file", info.Size, true), 10))
+	w.Write(head)
+
+	_, err = io.CopyN(w, data, info.Size)
+	if err != nil {
+		// headers are gone already, the client sees a short body
+		log.Printf("failed to send content zip: %s", err)
+		return
+	}
+
+	// an empty file is never read, its crc is 0 anyway
+	if !summed && info.Size != 0 {
+		log.Printf("failed to send content zip: file %d wasn't hashed", id)
+		return
+	}
+
+	_, tail, _ := zipHeaders("file", info.Size, crc, info.ModTime, true)
+	w.Write(tail)
 }
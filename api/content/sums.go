@@ -0,0 +1,84 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package content
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"hash/crc32"
+	"io"
+	"log/slog"
+)
+
+// summingReader hashes a content file while it's served, for files whose checksums aren't stored yet
+// only bytes read in order from the start count, so ranges and seeks just stop it from finishing
+// done is called once the whole file has gone through
+type summingReader struct {
+	io.ReadSeeker
+	size int64
+	done func(crc uint32, sum string)
+
+	pos    int64
+	summed int64
+	crc    hash.Hash32
+	sha    hash.Hash
+}
+
+func newSummingReader(r io.ReadSeeker, size int64, done func(crc uint32, sum string)) *summingReader {
+	return &summingReader{ReadSeeker: r, size: size, done: done, crc: crc32.NewIEEE(), sha: sha256.New()}
+}
+
+func (r *summingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadSeeker.Read(p)
+
+	if r.pos == r.summed {
+		r.crc.Write(p[:n])
+		r.sha.Write(p[:n])
+		r.summed += int64(n)
+
+		if r.summed == r.size && r.done != nil {
+			r.done(r.crc.Sum32(), hex.EncodeToString(r.sha.Sum(nil)))
+			r.done = nil
+		}
+	}
+
+	r.pos += int64(n)
+
+	return n, err
+}
+
+func (r *summingReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := r.ReadSeeker.Seek(offset, whence)
+	if err == nil {
+		r.pos = pos
+	}
+
+	return pos, err
+}
+
+// storeSums returns a done func for newSummingReader that stores the checksums of file id
+func (h *Handler) storeSums(id int) func(crc uint32, sum string) {
+	return func(crc uint32, sum string) {
+		err := h.Files.StoreFileSums(id, crc, sum)
+		if err != nil {
+			slog.Error("failed to store content file sums", "id", id, "error", err)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package content
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"time"
+)
+
+// a zip holding a single stored (uncompressed) file is small enough to lay out by hand,
+// which tells us its exact size before any of the file is read:
+// local file header, name, file data, central directory header, name, end of central directory
+//
+// when the crc isn't known up front a data descriptor holding it follows the file data,
+// the local header's crc is left as 0 and flag bit 3 tells readers to look after the data instead
+
+const (
+	zipLocalHeaderSize   = 30
+	zipDescriptorSize    = 16
+	zipCentralHeaderSize = 46
+	zipEndSize           = 22
+)
+
+var errZipTooLarge = errors.New("file too large for a zip without zip64")
+
+// zipSize is the length of a zip made by zipHeaders
+func zipSize(name string, size int64, descriptor bool) int64 {
+	n := zipLocalHeaderSize + zipCentralHeaderSize + zipEndSize + 2*int64(len(name)) + size
+	if descriptor {
+		n += zipDescriptorSize
+	}
+
+	return n
+}
+
+// zipHeaders returns the bytes that go before and after the file data
+// with descriptor set head doesn't depend on crc, so it can be sent before the data is read
+func zipHeaders(name string, size int64, crc uint32, modified time.Time, descriptor bool) (head []byte, tail []byte, err error) {
+	if size >= math.MaxUint32 || zipSize(name, size, descriptor) >= math.MaxUint32 {
+		return nil, nil, errZipTooLarge
+	}
+
+	dosTime, dosDate := zipTime(modified)
+
+	version, flags, localCRC := uint16(10), uint16(0), crc // 1.0
+	if descriptor {
+		version, flags, localCRC = 20, 1<<3, 0 // 2.0 added data descriptors
+	}
+
+	// shared by the local and central headers
+	entry := func(b []byte, crc uint32) []byte {
+		b = binary.LittleEndian.AppendUint16(b, version) // version needed to extract
+		b = binary.LittleEndian.AppendUint16(b, flags)
+		b = binary.LittleEndian.AppendUint16(b, 0) // method, stored
+		b = binary.LittleEndian.AppendUint16(b, dosTime)
+		b = binary.LittleEndian.AppendUint16(b, dosDate)
+		b = binary.LittleEndian.AppendUint32(b, crc)
+		b = binary.LittleEndian.AppendUint32(b, uint32(size)) // compressed
+		b = binary.LittleEndian.AppendUint32(b, uint32(size)) // uncompressed
+		b = binary.LittleEndian.AppendUint16(b, uint16(len(name)))
+		b = binary.LittleEndian.AppendUint16(b, 0) // extra length
+
+		return b
+	}
+
+	head = binary.LittleEndian.AppendUint32(head, 0x04034b50)
+	head = entry(head, localCRC)
+	head = append(head, name...)
+
+	if descriptor {
+		tail = binary.LittleEndian.AppendUint32(tail, 0x08074b50)
+		tail = binary.LittleEndian.AppendUint32(tail, crc)
+		tail = binary.LittleEndian.AppendUint32(tail, uint32(size)) // compressed
+		tail = binary.LittleEndian.AppendUint32(tail, uint32(size)) // uncompressed
+	}
+
+	central := len(tail)
+	tail = binary.LittleEndian.AppendUint32(tail, 0x02014b50)
+	tail = binary.LittleEndian.AppendUint16(tail, 20) // version made by, ms-dos 2.0
+	tail = entry(tail, crc)
+	tail = binary.LittleEndian.AppendUint16(tail, 0) // comment length
+	tail = binary.LittleEndian.AppendUint16(tail, 0) // disk number
+	tail = binary.LittleEndian.AppendUint16(tail, 0) // internal attributes
+	tail = binary.LittleEndian.AppendUint32(tail, 0) // external attributes
+	tail = binary.LittleEndian.AppendUint32(tail, 0) // local header offset
+	tail = append(tail, name...)
+
+	tail = binary.LittleEndian.AppendUint32(tail, 0x06054b50)
+	tail = binary.LittleEndian.AppendUint16(tail, 0) // disk number
+	tail = binary.LittleEndian.AppendUint16(tail, 0) // central directory disk
+	tail = binary.LittleEndian.AppendUint16(tail, 1) // entries on this disk
+	tail = binary.LittleEndian.AppendUint16(tail, 1) // entries
+	tail = binary.LittleEndian.AppendUint32(tail, uint32(zipCentralHeaderSize+len(name)))
+	tail = binary.LittleEndian.AppendUint32(tail, uint32(int64(len(head))+size+int64(central))) // central directory offset
+	tail = binary.LittleEndian.AppendUint16(tail, 0)                                            // comment length
+
+	return head, tail, nil
+}
+
+// zipTime converts t to ms-dos time and date, which can't go before 1980
+func zipTime(t time.Time) (uint16, uint16) {
+	t = t.UTC()
+	if t.Year() < 1980 {
+		t = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	return uint16(t.Hour()<<11 | t.Minute()<<5 | t.Second()/2), uint16((t.Year()-1980)<<9 | int(t.Month())<<5 | t.Day())
+}
+
+// zipReader serves a zip made by zipHeaders as one seekable stream
+// the file data is only read from where it's needed, so ranges don't fetch the whole file
+type zipReader struct {
+	parts []io.ReadSeeker
+	sizes []int64
+
+	pos  int64
+	cur  int  // part the last read came from
+	seek bool // cur has to be repositioned before reading
+}
+
+func newZipReader(head []byte, body io.ReadSeeker, size int64, tail []byte) *zipReader {
+	return &zipReader{
+		parts: []io.ReadSeeker{bytes.NewReader(head), body, bytes.NewReader(tail)},
+		sizes: []int64{int64(len(head)), size, int64(len(tail))},
+		cur:   -1,
+	}
+}
+
+func (z *zipReader) Read(p []byte) (int, error) {
+	// find the part holding pos
+	start := int64(0)
+	i := 0
+	for i < len(z.parts) && z.pos >= start+z.sizes[i] {
+		start += z.sizes[i]
+		i++
+	}
+
+	if i == len(z.parts) {
+		return 0, io.EOF
+	}
+
+	if i != z.cur || z.seek {
+		_, err := z.parts[i].Seek(z.pos-start, io.SeekStart)
+		if err != nil {
+			return 0, err
+		}
+
+		z.cur = i
+		z.seek = false
+	}
+
+	n, err := z.parts[i].Read(p[:min(int64(len(p)), start+z.sizes[i]-z.pos)])
+	z.pos += int64(n)
+
+	// the next read moves on to the next part
+	if err == io.EOF && z.pos == start+z.sizes[i] {
+		err = nil
+	}
+
+	return n, err
+}
+
+func (z *zipReader) Seek(offset int64, whence int) (int64, error) {
+	pos := z.pos
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos += offset
+	case io.SeekEnd:
+		pos = z.sizes[0] + z.sizes[1] + z.sizes[2] + offset
+	}
+
+	if pos < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	if pos != z.pos {
+		z.pos = pos
+		z.seek = true
+	}
+
+	return pos, nil
+}
@@ -23,12 +23,11 @@ import (
 	"fmt"
 	"net/http"
 
-	"github.com/flatgrassdotnet/cloudbox/db"
 	"github.com/flatgrassdotnet/cloudbox/utils"
 )
 
-func List(w http.ResponseWriter, r *http.Request) {
-	entries, err := db.FetchNewsEntries()
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	entries, err := h.News.FetchNewsEntries()
 	if err != nil {
 		utils.WriteError(w, r, fmt.Sprintf("failed to fetch news entries: %s", err))
 		return
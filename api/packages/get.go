@@ -26,11 +26,10 @@ import (
 	"net/http"
 	"strconv"
 
-	"github.com/flatgrassdotnet/cloudbox/db"
 	"github.com/flatgrassdotnet/cloudbox/utils"
 )
 
-func Get(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(r.URL.Query().Get("id"))
 	if err != nil {
 		utils.WriteError(w, r, fmt.Sprintf("failed to parse id value: %s", err))
@@ -39,14 +38,14 @@ func Get(w http.ResponseWriter, r *http.Request) {
 
 	rev, _ := strconv.Atoi(r.URL.Query().Get("rev"))
 	if rev < 1 {
-		rev, err = db.FetchPackageLatestRevision(id)
+		rev, err = h.Packages.FetchPackageLatestRevision(id)
 		if err != nil {
 			utils.WriteError(w, r, fmt.Sprintf("failed to fetch package latest revision: %s", err))
 			return
 		}
 	}
 
-	pkg, err := db.FetchPackage(id, rev)
+	pkg, err := h.Packages.FetchPackage(id, rev)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			http.Error(w, "package not found", http.StatusNotFound)
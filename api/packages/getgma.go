@@ -19,250 +19,166 @@
 package packages
 
 import (
-	"database/sql"
-	"encoding/binary"
+	"archive/zip"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
-	"regexp"
 	"strconv"
 	"strings"
 
 	"github.com/flatgrassdotnet/cloudbox/common"
-	"github.com/flatgrassdotnet/cloudbox/db"
+	"github.com/flatgrassdotnet/cloudbox/gma"
 	"github.com/flatgrassdotnet/cloudbox/utils"
 )
 
-type gmaDescription struct {
-	Description string   `json:"description"`
-	Type        string   `json:"type"`
-	Tags        []string `json:"tags"`
-}
-
-func GetGMA(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.Atoi(r.URL.Query().Get("id"))
-	if err != nil {
-		utils.WriteError(w, r, fmt.Sprintf("failed to parse id value: %s", err))
+func (h *Handler) GetGMA(w http.ResponseWriter, r *http.Request) {
+	pkg, includes, mode, ok := h.gmaRequest(w, r)
+	if !ok {
 		return
 	}
 
-	rev, _ := strconv.Atoi(r.URL.Query().Get("rev"))
-	if rev < 1 {
-		rev, err = db.FetchPackageLatestRevision(id)
-		if err != nil {
-			utils.WriteError(w, r, fmt.Sprintf("failed to fetch package latest revision: %s", err))
-			return
-		}
-	}
+	// lets clients tell a partial addon from a complete one, /packages/getgma/manifest has the details
+	w.Header().Set("X-Cloudbox-Excluded", strconv.Itoa(len(gmaManifest(pkg, includes, mode).Excluded)))
 
-	pkg, err := db.FetchPackage(id, rev)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			http.Error(w, "package not found", http.StatusNotFound)
-			return
-		}
-
-		utils.WriteError(w, r, fmt.Sprintf("failed to fetch package: %s", err))
+	if mode == "bundle" {
+		h.writeGMABundle(w, r, append([]common.Package{pkg}, includes...))
 		return
 	}
 
-	if len(pkg.Content) == 0 {
-		w.WriteHeader(http.StatusOK)
-		return
+	variant := ""
+	content := pkg.Content
+	required := gmaRequiredContent(pkg.Includes)
+	if mode == "merge" {
+		variant = "merge"
+		content = mergeContent(append([]common.Package{pkg}, includes...))
+		required = nil
 	}
 
-	// magic
-	w.Write([]byte("GMAD"))
-
-	// version
-	binary.Write(w, binary.LittleEndian, uint8(3))
-
-	// steamid (unused)
-	var author int
-	if pkg.Author != "" {
-		author, err = strconv.Atoi(pkg.Author)
-		if err != nil {
-			utils.WriteError(w, r, fmt.Sprintf("failed to convert author steamid: %s", err))
-			return
-		}
+	if len(content) == 0 {
+		w.WriteHeader(http.StatusOK)
+		return
 	}
 
-	binary.Write(w, binary.LittleEndian, uint64(author))
-
-	// timestamp
-	binary.Write(w, binary.LittleEndian, uint64(pkg.Uploaded.Unix()))
-
-	// required content (stubbed)
-	binary.Write(w, binary.LittleEndian, uint8(0))
-
-	// addon name
-	w.Write([]byte(pkg.Name + "\000"))
-
-	// addon description
-	err = json.NewEncoder(w).Encode(gmaDescription{
-		Description: pkg.Description,
-		Type:        pkg.Type,
-		Tags:        []string{"fun"},
-	})
+	a, err := h.buildGMA(pkg, variant, content, required)
 	if err != nil {
-		utils.WriteError(w, r, fmt.Sprintf("failed to marshal package description: %s", err))
+		utils.WriteError(w, r, fmt.Sprintf("failed to build gma: %s", err))
 		return
 	}
 
-	binary.Write(w, binary.LittleEndian, uint8(0)) // null terminator
+	body := h.GMAs.OpenGMAArtifact(r.Context(), a)
+	defer body.Close()
 
-	// addon author (unused)
-	w.Write([]byte(pkg.AuthorName + "\000"))
+	// handles Range, If-Range and If-None-Match
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("ETag", fmt.Sprintf("\"%s\"", a.SHA256))
+	http.ServeContent(w, r, "", a.Created, body)
+}
 
-	// addon version (unused)
-	binary.Write(w, binary.LittleEndian, uint32(pkg.Revision))
+// writeGMABundle sends a zip with a gma for every package that has content
+// each gma lists the packages it includes as required content, named like the files in the zip
+func (h *Handler) writeGMABundle(w http.ResponseWriter, r *http.Request, pkgs []common.Package) {
+	var names []string
+	var artifacts []common.GMAArtifact
+	for _, p := range pkgs {
+		if len(p.Content) == 0 {
+			continue
+		}
 
-	// exclude non-whitelisted files
-	var content []common.Content
-	for _, item := range pkg.Content {
-		whitelisted, err := isPathWhitelisted(item.Path)
+		a, err := h.buildGMA(p, "", p.Content, gmaRequiredContent(p.Includes))
 		if err != nil {
-			utils.WriteError(w, r, fmt.Sprintf("failed to check if path is whitelisted: %s", err))
+			utils.WriteError(w, r, fmt.Sprintf("failed to build gma for package %d: %s", p.ID, err))
 			return
 		}
 
-		if whitelisted {
-			content = append(content, item)
-		}
+		names = append(names, gmaName(p.Type, p.ID, p.Revision)+".gma")
+		artifacts = append(artifacts, a)
 	}
 
-	// file list
-	for i, item := range content {
-		// file number
-		binary.Write(w, binary.LittleEndian, uint32(i+1))
-
-		// file name
-		w.Write([]byte(strings.ToLower(item.Path) + "\000"))
-
-		// file size
-		binary.Write(w, binary.LittleEndian, uint64(item.Size))
-
-		// file crc (skipped)
-		binary.Write(w, binary.LittleEndian, uint32(0))
+	if len(artifacts) == 0 {
+		w.WriteHeader(http.StatusOK)
+		return
 	}
 
-	// end of file list marker
-	binary.Write(w, binary.LittleEndian, uint32(0))
+	w.Header().Set("Content-Type", "application/zip")
 
-	// file content
-	for _, item := range content {
-		o, err := db.GetContentFile(item.ID)
+	// gmas don't compress well, store them as is
+	zw := zip.NewWriter(w)
+	for i, a := range artifacts {
+		file, err := zw.CreateHeader(&zip.FileHeader{Name: names[i], Method: zip.Store, Modified: a.Created})
 		if err != nil {
-			utils.WriteError(w, r, fmt.Sprintf("failed to get content file data: %s", err))
+			log.Printf("failed to write gma bundle: %s", err)
 			return
 		}
 
-		defer o.Body.Close()
-
-		io.Copy(w, o.Body)
+		body := h.GMAs.OpenGMAArtifact(r.Context(), a)
+		_, err = io.Copy(file, body)
+		body.Close()
+		if err != nil {
+			// headers are gone already, a zip without its central directory is the best we can do
+			log.Printf("failed to write gma bundle: %s", err)
+			return
+		}
 	}
 
-	// content crc (skipped)
-	binary.Write(w, binary.LittleEndian, uint32(0))
+	zw.Close()
 }
 
-var gmaWhitelist = map[string]bool{
-	"^lua/(.*).lua$":                               true,
-	"^scenes/(.*).vcd$":                            true,
-	"^particles/(.*).pcf$":                         true,
-	"^resource/fonts/(.*).ttf$":                    true,
-	"^scripts/vehicles/(.*).txt$":                  true,
-	"^resource/localization/(.*)/(.*).properties$": true,
-	"^maps/(.*).bsp$":                              true,
-	"^maps/(.*).lmp$":                              true,
-	"^maps/(.*).nav$":                              true,
-	"^maps/(.*).ain$":                              true,
-	"^maps/thumb/(.*).png$":                        true,
-	"^sound/(.*).wav$":                             true,
-	"^sound/(.*).mp3$":                             true,
-	"^sound/(.*).ogg$":                             true,
-	"^materials/(.*).vmt$":                         true,
-	"^materials/(.*).vtf$":                         true,
-	"^materials/(.*).png$":                         true,
-	"^materials/(.*).jpg$":                         true,
-	"^materials/(.*).jpeg$":                        true,
-	"^materials/colorcorrection/(.*).raw$":         true,
-	"^models/(.*).mdl$":                            true,
-	"^models/(.*).phy$":                            true,
-	"^models/(.*).ani$":                            true,
-	"^models/(.*).vvd$":                            true,
-
-	"^models/(.*).vtx$":       true,
-	"^!models/(.*).sw.vtx$":   false, // These variations are unused by the game
-	"^!models/(.*).360.vtx$":  false,
-	"^!models/(.*).xbox.vtx$": false,
-
-	"^gamemodes/(.*)/(.*).txt$":       true,
-	"^!gamemodes/(.*)/(.*)/(.*).txt$": false, // Only in the root gamemode folder please!
-	"^gamemodes/(.*)/(.*).fgd$":       true,
-	"^!gamemodes/(.*)/(.*)/(.*).fgd$": false,
-
-	"^gamemodes/(.*)/logo.png$":                   true,
-	"^gamemodes/(.*)/icon24.png$":                 true,
-	"^gamemodes/(.*)/gamemode/(.*).lua$":          true,
-	"^gamemodes/(.*)/entities/effects/(.*).lua$":  true,
-	"^gamemodes/(.*)/entities/weapons/(.*).lua$":  true,
-	"^gamemodes/(.*)/entities/entities/(.*).lua$": true,
-	"^gamemodes/(.*)/backgrounds/(.*).png$":       true,
-	"^gamemodes/(.*)/backgrounds/(.*).jpg$":       true,
-	"^gamemodes/(.*)/backgrounds/(.*).jpeg$":      true,
-	"^gamemodes/(.*)/content/models/(.*).mdl$":    true,
-	"^gamemodes/(.*)/content/models/(.*).phy$":    true,
-	"^gamemodes/(.*)/content/models/(.*).ani$":    true,
-	"^gamemodes/(.*)/content/models/(.*).vvd$":    true,
-
-	"^gamemodes/(.*)/content/models/(.*).vtx$":       true,
-	"^!gamemodes/(.*)/content/models/(.*).sw.vtx$":   false,
-	"^!gamemodes/(.*)/content/models/(.*).360.vtx$":  false,
-	"^!gamemodes/(.*)/content/models/(.*).xbox.vtx$": false,
-
-	"^gamemodes/(.*)/content/materials/(.*).vmt$":                         true,
-	"^gamemodes/(.*)/content/materials/(.*).vtf$":                         true,
-	"^gamemodes/(.*)/content/materials/(.*).png$":                         true,
-	"^gamemodes/(.*)/content/materials/(.*).jpg$":                         true,
-	"^gamemodes/(.*)/content/materials/(.*).jpeg$":                        true,
-	"^gamemodes/(.*)/content/materials/colorcorrection/(.*).raw$":         true,
-	"^gamemodes/(.*)/content/scenes/(.*).vcd$":                            true,
-	"^gamemodes/(.*)/content/particles/(.*).pcf$":                         true,
-	"^gamemodes/(.*)/content/resource/fonts/(.*).ttf$":                    true,
-	"^gamemodes/(.*)/content/scripts/vehicles/(.*).txt$":                  true,
-	"^gamemodes/(.*)/content/resource/localization/(.*)/(.*).properties$": true,
-	"^gamemodes/(.*)/content/maps/(.*).bsp$":                              true,
-	"^gamemodes/(.*)/content/maps/(.*).nav$":                              true,
-	"^gamemodes/(.*)/content/maps/(.*).ain$":                              true,
-	"^gamemodes/(.*)/content/maps/thumb/(.*).png$":                        true,
-	"^gamemodes/(.*)/content/sound/(.*).wav$":                             true,
-	"^gamemodes/(.*)/content/sound/(.*).mp3$":                             true,
-	"^gamemodes/(.*)/content/sound/(.*).ogg$":                             true,
+// buildGMA returns the stored gma of content for pkg, building it if needed
+func (h *Handler) buildGMA(pkg common.Package, variant string, content []common.Content, required []string) (common.GMAArtifact, error) {
+	// steamid (unused)
+	var author int
+	if pkg.Author != "" {
+		var err error
+		author, err = strconv.Atoi(pkg.Author)
+		if err != nil {
+			return common.GMAArtifact{}, fmt.Errorf("failed to convert author steamid: %s", err)
+		}
+	}
 
-	// static version of the data/ folder
-	// (because you wouldn't be able to modify these)
-	// We only allow filetypes here that are not already allowed above
-	"^data_static/(.*).txt$":  true,
-	"^data_static/(.*).dat$":  true,
-	"^data_static/(.*).json$": true,
-	"^data_static/(.*).xml$":  true,
-	"^data_static/(.*).csv$":  true,
-}
+	// exclude non-whitelisted files
+	var included []common.Content
+	for _, item := range content {
+		if gma.DefaultWhitelist.Allowed(item.Path) {
+			included = append(included, item)
+		}
+	}
 
-func isPathWhitelisted(path string) (bool, error) {
-	for rule, allowed := range gmaWhitelist {
-		matched, err := regexp.MatchString(rule, path)
+	// the file list needs every crc before any content is written
+	files := make([]gma.File, len(included))
+	for i, item := range included {
+		crc, err := h.Files.FetchFileCRC(item.ID)
 		if err != nil {
-			return false, err
+			return common.GMAArtifact{}, fmt.Errorf("failed to fetch content file crc: %s", err)
 		}
 
-		if matched {
-			return allowed, nil
+		files[i] = gma.File{
+			Name: strings.ToLower(item.Path),
+			Size: int64(item.Size),
+			CRC:  crc,
 		}
 	}
 
-	return false, nil
+	meta, err := gmaMetadata(pkg)
+	if err != nil {
+		return common.GMAArtifact{}, fmt.Errorf("failed to map package type: %s", err)
+	}
+
+	desc, err := json.Marshal(meta)
+	if err != nil {
+		return common.GMAArtifact{}, fmt.Errorf("failed to marshal package description: %s", err)
+	}
+
+	header := gma.Header{
+		SteamID:         uint64(author),
+		Timestamp:       pkg.Uploaded,
+		RequiredContent: required,
+		Name:            pkg.Name,
+		Description:     string(desc),
+		Author:          pkg.AuthorName,      // unused
+		AddonVersion:    int32(pkg.Revision), // unused
+	}
+
+	return h.gmaArtifact(pkg, variant, header, files, included)
 }
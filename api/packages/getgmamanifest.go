@@ -0,0 +1,139 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package packages
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/flatgrassdotnet/cloudbox/common"
+	"github.com/flatgrassdotnet/cloudbox/gma"
+	"github.com/flatgrassdotnet/cloudbox/utils"
+)
+
+// GetGMAManifest lists which files of a package GetGMA puts in the addon and which it leaves out
+// it takes the same parameters as GetGMA
+func (h *Handler) GetGMAManifest(w http.ResponseWriter, r *http.Request) {
+	pkg, includes, mode, ok := h.gmaRequest(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(gmaManifest(pkg, includes, mode))
+	if err != nil {
+		utils.WriteError(w, r, fmt.Sprintf("failed to encode manifest: %s", err))
+		return
+	}
+}
+
+// gmaRequest fetches the package a getgma request is for and, if asked, the packages it includes
+// mode is "", "merge" or "bundle", ok is false if an error was written
+func (h *Handler) gmaRequest(w http.ResponseWriter, r *http.Request) (pkg common.Package, includes []common.Package, mode string, ok bool) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		utils.WriteError(w, r, fmt.Sprintf("failed to parse id value: %s", err))
+		return
+	}
+
+	rev, _ := strconv.Atoi(r.URL.Query().Get("rev"))
+	if rev < 1 {
+		rev, err = h.Packages.FetchPackageLatestRevision(id)
+		if err != nil {
+			utils.WriteError(w, r, fmt.Sprintf("failed to fetch package latest revision: %s", err))
+			return
+		}
+	}
+
+	pkg, err = h.Packages.FetchPackage(id, rev)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "package not found", http.StatusNotFound)
+			return
+		}
+
+		utils.WriteError(w, r, fmt.Sprintf("failed to fetch package: %s", err))
+		return
+	}
+
+	// optionally take the packages it includes along, merged into one gma or as a zip of gmas
+	mode = r.URL.Query().Get("deps")
+	if mode != "" && mode != "merge" && mode != "bundle" {
+		utils.WriteError(w, r, fmt.Sprintf("unknown deps value: %s", mode))
+		return
+	}
+
+	if mode != "" {
+		includes, err = h.resolveIncludes(pkg)
+		if err != nil {
+			utils.WriteError(w, r, fmt.Sprintf("failed to resolve includes: %s", err))
+			return
+		}
+	}
+
+	return pkg, includes, mode, true
+}
+
+// gmaManifest checks every file GetGMA would consider against the whitelist
+func gmaManifest(pkg common.Package, includes []common.Package, mode string) common.GMAManifest {
+	m := common.GMAManifest{
+		ID:       pkg.ID,
+		Revision: pkg.Revision,
+		Deps:     mode,
+		Included: []common.GMAManifestFile{},
+		Excluded: []common.GMAManifestFile{},
+	}
+
+	// same order and precedence as mergeContent
+	seen := make(map[string]bool)
+	for _, p := range append([]common.Package{pkg}, includes...) {
+		for _, item := range p.Content {
+			path := strings.ToLower(item.Path)
+			if mode == "merge" {
+				if seen[path] {
+					continue
+				}
+
+				seen[path] = true
+			}
+
+			rule, ok := gma.DefaultWhitelist.Match(path)
+
+			f := common.GMAManifestFile{
+				Package:  p.ID,
+				Revision: p.Revision,
+				Path:     path,
+				Rule:     rule,
+			}
+
+			if ok {
+				m.Included = append(m.Included, f)
+			} else {
+				m.Excluded = append(m.Excluded, f)
+			}
+		}
+	}
+
+	return m
+}
@@ -0,0 +1,190 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package packages
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/flatgrassdotnet/cloudbox/common"
+	"github.com/flatgrassdotnet/cloudbox/gma"
+)
+
+// bump when the gma output changes for the same inputs, so stored builds are replaced
+const gmaFormat = 1
+
+// revisions being built, so concurrent requests wait for one build instead of each starting their own
+// entries go away once nothing holds or waits on them
+var gmaBuilds = struct {
+	sync.Mutex
+	m map[string]*gmaBuild // "id_rev_variant"
+}{m: make(map[string]*gmaBuild)}
+
+type gmaBuild struct {
+	sync.Mutex
+	refs int
+}
+
+// lockGMABuild waits for any other build of key to finish, the returned func unlocks it
+func lockGMABuild(key string) func() {
+	gmaBuilds.Lock()
+	b, ok := gmaBuilds.m[key]
+	if !ok {
+		b = new(gmaBuild)
+		gmaBuilds.m[key] = b
+	}
+	b.refs++
+	gmaBuilds.Unlock()
+
+	b.Lock()
+
+	return func() {
+		b.Unlock()
+
+		gmaBuilds.Lock()
+		b.refs--
+		if b.refs == 0 {
+			delete(gmaBuilds.m, key)
+		}
+		gmaBuilds.Unlock()
+	}
+}
+
+// gmaFingerprint hashes everything that ends up in a gma
+// content is identified by crc, the same path and crc means the same bytes
+func gmaFingerprint(header gma.Header, files []gma.File) (string, error) {
+	data, err := json.Marshal(struct {
+		Format int
+		Header gma.Header
+		Files  []gma.File
+	}{gmaFormat, header, files})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// gmaArtifact returns the stored gma for pkg, building and storing it first if it's missing or outdated
+func (h *Handler) gmaArtifact(pkg common.Package, variant string, header gma.Header, files []gma.File, content []common.Content) (common.GMAArtifact, error) {
+	fingerprint, err := gmaFingerprint(header, files)
+	if err != nil {
+		return common.GMAArtifact{}, err
+	}
+
+	a, ok, err := h.storedGMA(pkg, variant, fingerprint)
+	if err != nil || ok {
+		return a, err
+	}
+
+	unlock := lockGMABuild(fmt.Sprintf("%d_%d_%s", pkg.ID, pkg.Revision, variant))
+	defer unlock()
+
+	// another request may have finished building it while we waited
+	a, ok, err = h.storedGMA(pkg, variant, fingerprint)
+	if err != nil || ok {
+		return a, err
+	}
+
+	// build to disk first so a failure never reaches the client as a truncated download
+	f, err := os.CreateTemp("", "cloudbox-*.gma")
+	if err != nil {
+		return common.GMAArtifact{}, err
+	}
+
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	sum := sha256.New()
+
+	err = h.writeGMA(io.MultiWriter(f, sum), header, files, content)
+	if err != nil {
+		return common.GMAArtifact{}, err
+	}
+
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return common.GMAArtifact{}, err
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return common.GMAArtifact{}, err
+	}
+
+	return h.GMAs.StoreGMAArtifact(common.GMAArtifact{
+		ID:          pkg.ID,
+		Revision:    pkg.Revision,
+		Variant:     variant,
+		Fingerprint: fingerprint,
+		Size:        size,
+		SHA256:      hex.EncodeToString(sum.Sum(nil)),
+		Created:     time.Now().UTC().Truncate(time.Second),
+	}, f)
+}
+
+// storedGMA looks up the stored build of pkg, ok is false if there is none matching fingerprint
+func (h *Handler) storedGMA(pkg common.Package, variant string, fingerprint string) (common.GMAArtifact, bool, error) {
+	a, err := h.GMAs.FetchGMAArtifact(pkg.ID, pkg.Revision, variant)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return common.GMAArtifact{}, false, nil
+		}
+
+		return common.GMAArtifact{}, false, err
+	}
+
+	return a, a.Fingerprint == fingerprint, nil
+}
+
+// writeGMA streams a gma, reading content files one at a time
+func (h *Handler) writeGMA(w io.Writer, header gma.Header, files []gma.File, content []common.Content) error {
+	gw := gma.NewWriter(w)
+
+	err := gw.WriteHeader(header, files)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range content {
+		o, err := h.Files.GetContentFile(item.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get content file %d: %s", item.ID, err)
+		}
+
+		err = gw.WriteFile(o.Body)
+		o.Body.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	// content crc
+	return gw.Close()
+}
@@ -0,0 +1,101 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package packages
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/flatgrassdotnet/cloudbox/common"
+	"github.com/flatgrassdotnet/cloudbox/gma"
+)
+
+// what toybox packages become as addons, unknown types end up as servercontent
+var gmaTypes = map[string]struct {
+	Type string
+	Tag  string // used when nothing in the name or description suggests one
+}{
+	"entity":  {"entity", "fun"},
+	"weapon":  {"weapon", "fun"},
+	"npc":     {"npc", "fun"},
+	"vehicle": {"vehicle", "fun"},
+	"prop":    {"model", "build"},
+	"map":     {"map", "scenic"},
+	"savemap": {"map", "build"}, // saves are built on top of a map
+}
+
+// words in a package name or description that suggest a tag
+var gmaTagWords = map[string]string{
+	"fun":         "fun",
+	"funny":       "fun",
+	"roleplay":    "roleplay",
+	"rp":          "roleplay",
+	"scenic":      "scenic",
+	"scenery":     "scenic",
+	"landscape":   "scenic",
+	"movie":       "movie",
+	"film":        "movie",
+	"machinima":   "movie",
+	"realism":     "realism",
+	"realistic":   "realism",
+	"cartoon":     "cartoon",
+	"toon":        "cartoon",
+	"water":       "water",
+	"boat":        "water",
+	"ocean":       "water",
+	"comic":       "comic",
+	"build":       "build",
+	"building":    "build",
+	"contraption": "build",
+}
+
+// gmaMetadata describes pkg the way addon.json would
+func gmaMetadata(pkg common.Package) (gma.Metadata, error) {
+	t, ok := gmaTypes[pkg.Type]
+	if !ok {
+		t.Type = "servercontent"
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(pkg.Name+" "+pkg.Description), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	// gma.Tags order, so the same words always give the same tags
+	var tags []string
+	for _, tag := range gma.Tags {
+		for _, word := range words {
+			if gmaTagWords[word] == tag {
+				tags = append(tags, tag)
+				break
+			}
+		}
+	}
+
+	if len(tags) == 0 && t.Tag != "" {
+		tags = append(tags, t.Tag)
+	}
+
+	m := gma.Metadata{
+		Description: pkg.Description,
+		Type:        t.Type,
+		Tags:        tags[:min(len(tags), gma.MaxTags)],
+	}
+
+	return m, m.Validate()
+}
@@ -0,0 +1,116 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package packages
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/flatgrassdotnet/cloudbox/common"
+)
+
+var ErrIncludeCycle = errors.New("include cycle")
+
+// resolveIncludes walks the include graph of pkg and returns every package it depends on
+// dependencies come before the packages including them and each revision is listed once
+func (h *Handler) resolveIncludes(pkg common.Package) ([]common.Package, error) {
+	const (
+		visiting = iota + 1
+		done
+	)
+
+	state := make(map[[2]int]int) // id, rev
+	var path []string             // for cycle errors
+	var order []common.Package
+
+	var visit func(p common.Package) error
+	visit = func(p common.Package) error {
+		state[[2]int{p.ID, p.Revision}] = visiting
+		path = append(path, fmt.Sprintf("%d@%d", p.ID, p.Revision))
+
+		for _, i := range p.Includes {
+			switch state[[2]int{i.ID, i.Revision}] {
+			case visiting:
+				return fmt.Errorf("%w: %s -> %d@%d", ErrIncludeCycle, strings.Join(path, " -> "), i.ID, i.Revision)
+			case done:
+				continue
+			}
+
+			ip, err := h.Packages.FetchPackage(i.ID, i.Revision)
+			if err != nil {
+				return fmt.Errorf("failed to fetch included package %d@%d: %s", i.ID, i.Revision, err)
+			}
+
+			err = visit(ip)
+			if err != nil {
+				return err
+			}
+		}
+
+		state[[2]int{p.ID, p.Revision}] = done
+		path = path[:len(path)-1]
+		order = append(order, p)
+
+		return nil
+	}
+
+	err := visit(pkg)
+	if err != nil {
+		return nil, err
+	}
+
+	// pkg itself is last
+	return order[:len(order)-1], nil
+}
+
+// mergeContent combines the content of several packages
+// when more than one package ships the same path, the earliest one wins
+func mergeContent(pkgs []common.Package) []common.Content {
+	seen := make(map[string]bool)
+
+	var content []common.Content
+	for _, p := range pkgs {
+		for _, item := range p.Content {
+			path := strings.ToLower(item.Path)
+			if seen[path] {
+				continue
+			}
+
+			seen[path] = true
+			content = append(content, item)
+		}
+	}
+
+	return content
+}
+
+// gmaName names the gma of a package revision in bundles and required content
+func gmaName(typ string, id int, rev int) string {
+	return fmt.Sprintf("%s_%d_%d", typ, id, rev)
+}
+
+func gmaRequiredContent(includes []common.Include) []string {
+	var required []string
+	for _, i := range includes {
+		required = append(required, gmaName(i.Type, i.ID, i.Revision))
+	}
+
+	return required
+}
@@ -24,11 +24,10 @@ import (
 	"net/http"
 	"strconv"
 
-	"github.com/flatgrassdotnet/cloudbox/db"
 	"github.com/flatgrassdotnet/cloudbox/utils"
 )
 
-func List(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
 	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
 	if offset < 0 {
 		offset = 0
@@ -42,19 +41,14 @@ func List(w http.ResponseWriter, r *http.Request) {
 		count = 100
 	}
 
-	var sort string // must NOT be user input
-	switch r.URL.Query().Get("sort") {
-	case "popular":
-		sort = "COALESCE(s.favorites, 0)"
-	case "random":
-		sort = "RAND()"
-	default: // newest
-		sort = "id"
+	sort := r.URL.Query().Get("sort")
+	if sort != "popular" && sort != "random" {
+		sort = "newest"
 	}
 
 	safemode, _ := strconv.ParseBool(r.URL.Query().Get("safemode"))
 
-	list, err := db.FetchPackageList(r.URL.Query().Get("type"), r.URL.Query().Get("dataname"), r.URL.Query().Get("author"), r.URL.Query().Get("search"), offset, count, sort, safemode)
+	list, err := h.Packages.FetchPackageList(r.URL.Query().Get("type"), r.URL.Query().Get("dataname"), r.URL.Query().Get("author"), r.URL.Query().Get("search"), offset, count, sort, safemode)
 	if err != nil {
 		utils.WriteError(w, r, fmt.Sprintf("failed to fetch package list: %s", err))
 		return
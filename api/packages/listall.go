@@ -24,11 +24,10 @@ import (
 	"net/http"
 	"strconv"
 
-	"github.com/flatgrassdotnet/cloudbox/db"
 	"github.com/flatgrassdotnet/cloudbox/utils"
 )
 
-func ListAll(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) ListAll(w http.ResponseWriter, r *http.Request) {
 	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
 	if offset < 0 {
 		offset = 0
@@ -42,17 +41,12 @@ func ListAll(w http.ResponseWriter, r *http.Request) {
 		count = 100
 	}
 
-	var sort string // must NOT be user input
-	switch r.URL.Query().Get("sort") {
-	case "popular":
-		sort = "COALESCE(favorites, 0)"
-	case "random":
-		sort = "RAND()"
-	default: // newest
-		sort = "id"
+	sort := r.URL.Query().Get("sort")
+	if sort != "popular" && sort != "random" {
+		sort = "newest"
 	}
 
-	list, err := db.FetchPackageListAll(r.URL.Query().Get("type"), r.URL.Query().Get("dataname"), r.URL.Query().Get("author"), r.URL.Query().Get("search"), offset, count, sort)
+	list, err := h.Packages.FetchPackageListAll(r.URL.Query().Get("type"), r.URL.Query().Get("dataname"), r.URL.Query().Get("author"), r.URL.Query().Get("search"), offset, count, sort)
 	if err != nil {
 		utils.WriteError(w, r, fmt.Sprintf("failed to fetch package list: %s", err))
 		return
@@ -19,109 +19,299 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
-	"github.com/flatgrassdotnet/cloudbox/api/auth"
-	"github.com/flatgrassdotnet/cloudbox/api/content"
-	"github.com/flatgrassdotnet/cloudbox/api/news"
-	"github.com/flatgrassdotnet/cloudbox/api/packages"
+	"github.com/flatgrassdotnet/cloudbox/common"
+	"github.com/flatgrassdotnet/cloudbox/config"
 	"github.com/flatgrassdotnet/cloudbox/db"
-	"github.com/flatgrassdotnet/cloudbox/ingame/publishsave"
-	"github.com/flatgrassdotnet/cloudbox/ingame/stats"
-	"github.com/flatgrassdotnet/cloudbox/ingame/toyboxapi"
+	"github.com/flatgrassdotnet/cloudbox/deps"
+	"github.com/flatgrassdotnet/cloudbox/events"
+	"github.com/flatgrassdotnet/cloudbox/health"
 	"github.com/flatgrassdotnet/cloudbox/utils"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
-	dbuser := flag.String("dbuser", "cloudbox", "database user's name")
-	dbpass := flag.String("dbpass", "", "database user's password")
-	dbproto := flag.String("dbproto", "tcp", "database connection protocol")
-	dbaddr := flag.String("dbaddr", "localhost", "database server address")
-	dbname := flag.String("dbname", "cloudbox", "database name")
-	apikey := flag.String("apikey", "", "steam web api key")
-	statswebhook := flag.String("statswebhook", "", "discord stats webhook url")
-	savewebhook := flag.String("savewebhook", "", "discord save webhook url")
-	proto := flag.String("proto", "tcp", "proto for web server")
-	addr := flag.String("addr", "127.0.0.1:80", "address for web server")
-	flag.Parse()
-
-	err := db.Init(*dbuser, *dbpass, *dbproto, *dbaddr, *dbname)
+	cfg, err := config.Parse(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatalf("failed to load config: %s", err)
+	}
+
+	if cfg.PrintConfig {
+		err = cfg.Print(os.Stdout)
+		if err != nil {
+			log.Fatalf("failed to print config: %s", err)
+		}
+
+		return
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		log.Fatalf("invalid config: %s", err)
+	}
+
+	slog.SetDefault(cfg.Logger())
+
+	var database *db.DB
+	switch cfg.DBDriver {
+	case "mysql":
+		database, err = db.Open(cfg.DBUser, cfg.DBPass, cfg.DBProto, cfg.DBAddr, cfg.DBName)
+	case "sqlite":
+		database, err = db.OpenSQLite(cfg.DBPath)
+	}
 	if err != nil {
 		log.Fatalf("failed to init database: %s", err)
 	}
 
-	utils.SteamAPIKey = *apikey
-	utils.DiscordStatsWebhookURL = *statswebhook
-	utils.DiscordSaveWebhookURL = *savewebhook
+	// subcommands
+	switch flag.Arg(0) {
+	case "":
+	case "migrate":
+		err = runMigrate(database, flag.Args()[1:])
+		if err != nil {
+			log.Fatalf("failed to migrate database: %s", err)
+		}
+
+		return
+	case "content":
+		err = database.InitStorage(cfg.Storage, cfg.StorageDir, cfg.ContentBucket, cfg.ImageBucket)
+		if err != nil {
+			log.Fatalf("failed to init storage: %s", err)
+		}
+
+		err = runContent(database, flag.Args()[1:])
+		if err != nil {
+			log.Fatalf("failed to manage content: %s", err)
+		}
+
+		return
+	default:
+		log.Fatalf("unknown command: %s", flag.Arg(0))
+	}
+
+	err = database.InitStorage(cfg.Storage, cfg.StorageDir, cfg.ContentBucket, cfg.ImageBucket)
+	if err != nil {
+		log.Fatalf("failed to init storage: %s", err)
+	}
+
+	ready := &health.Handler{
+		DB:             database,
+		Timeout:        cfg.ReadyTimeout,
+		PublicInterval: 5 * time.Second,
+		Checks: []health.Check{
+			{Name: "storage", Func: database.PingStorage},
+			{Name: "steam", Func: func(ctx context.Context) error {
+				if cfg.APIKey == "" {
+					return errors.New("steam api key not configured")
+				}
 
-	// cloudbox api
-	http.HandleFunc("GET /auth/getid", auth.GetID)
-	http.HandleFunc("GET /news/list", news.List)
-	http.HandleFunc("GET /packages/list", packages.List)
-	http.HandleFunc("GET /packages/listall", packages.ListAll)
-	http.HandleFunc("GET /packages/get", packages.Get)
-	http.HandleFunc("GET /packages/getscript", packages.GetScript)
-	http.HandleFunc("GET /packages/getgma", packages.GetGMA)
-	http.HandleFunc("GET /content/get", content.Get)
-	http.HandleFunc("GET /content/getzip", content.GetZIP)
-	http.HandleFunc("GET /content/fastdl", content.FastDL)
+				return nil
+			}},
+		},
+	}
+
+	if cfg.FailFast {
+		report := ready.Run(context.Background())
+		for name, res := range report.Checks {
+			if res.Status != "ok" {
+				log.Printf("%s check failed: %s", name, res.Error)
+			}
+		}
 
-	// stats.garrysmod.com (routed to toyboxapi)
-	http.HandleFunc("GET toyboxapi.garrysmod.com/mapload_001/", stats.MapLoad) // v102 - v142
+		if !report.OK() {
+			log.Fatalf("dependencies unavailable, refusing to start")
+		}
+	}
 
-	// toyboxapi.garrysmod.com
-	// auth
-	http.HandleFunc("GET toyboxapi.garrysmod.com/auth_001/", toyboxapi.Auth)  // v104 - v106
-	http.HandleFunc("POST toyboxapi.garrysmod.com/auth_002/", toyboxapi.Auth) // v107 - v133
-	http.HandleFunc("POST toyboxapi.garrysmod.com/auth_003/", toyboxapi.Auth) // v134 - v142
+	common.ContentURL = cfg.ContentURL
+	utils.ImageURL = cfg.ImageURL
 
-	// getinstall
-	http.HandleFunc("GET toyboxapi.garrysmod.com/getinstall_003/", toyboxapi.GetPackage) // v134 - v142
+	// the stats webhook also gets api errors straight from utils.WriteError
+	stats := utils.NewDiscordQueue("stats", cfg.StatsWebhook, cfg.DiscordQueue)
+	utils.DiscordStatsQueue = stats
 
-	// getscript
-	http.HandleFunc("GET toyboxapi.garrysmod.com/getscript_001/", toyboxapi.GetPackage) // v100 - v133
-	http.HandleFunc("GET toyboxapi.garrysmod.com/getscript_003/", toyboxapi.GetPackage) // v134 - v142
+	bus := &events.Bus{}
+	bus.Subscribe(events.NewDiscordSink(stats), events.TypeLogin, events.TypePackageDownload, events.TypeMapLoad, events.TypeLuaError)
+	bus.Subscribe(events.NewDiscordSink(utils.NewDiscordQueue("save", cfg.SaveWebhook, cfg.DiscordQueue)), events.TypeSavePublished)
 
-	// upload
-	http.HandleFunc("POST toyboxapi.garrysmod.com/upload_001/", toyboxapi.Upload) // v109 - v133
-	http.HandleFunc("POST toyboxapi.garrysmod.com/upload_003/", toyboxapi.Upload) // v134 - v142
+	for _, c := range cfg.Sinks {
+		sink, err := events.NewSink(c)
+		if err != nil {
+			log.Fatalf("failed to create %s event sink: %s", c.Name, err)
+		}
 
-	// error
-	http.HandleFunc("GET toyboxapi.garrysmod.com/error_001/", toyboxapi.Error) // v98 - v133
-	http.HandleFunc("GET toyboxapi.garrysmod.com/error_003/", toyboxapi.Error) // v134 - v142
+		bus.Subscribe(sink, c.Events...)
+	}
 
-	// publishsave
-	http.HandleFunc("GET toyboxapi.garrysmod.com/publishsave_001/", publishsave.Save) // v106 - v108
-	http.HandleFunc("GET toyboxapi.garrysmod.com/publishsave_002/", publishsave.Save) // v109 - v142
+	mux := http.NewServeMux()
+	routes(mux, deps.Deps{
+		Packages: database,
+		Uploads:  database,
+		Sessions: database,
+		Stats:    database,
+		News:     database,
+		Files:    database,
+		GMAs:     database,
+		Steam:    &utils.SteamAPI{Key: cfg.APIKey, Cache: database},
+		Events:   bus,
+	})
 
-	http.HandleFunc("POST toyboxapi.garrysmod.com/publishsave_001/", publishsave.Publish) // v106 - v108
-	http.HandleFunc("POST toyboxapi.garrysmod.com/publishsave_002/", publishsave.Publish) // v109 - v142
+	// load balancers usually only reach this listener, the detailed report stays on the admin one
+	mux.HandleFunc("GET /healthz", ready.Healthz)
+	mux.HandleFunc("GET /readyz", ready.PublicReadyz)
 
 	// http stuff
-	if *proto == "unix" {
-		err = os.Remove(*addr)
-		if err != nil && !os.IsNotExist(err) {
-			log.Fatalf("failed to delete unix socket: %s", err)
+	handler := utils.LogRequests(mux)
+	plain := handler
+	var servers []*http.Server
+
+	errs := make(chan error, 3)
+
+	// optional https listener for clients that can speak modern tls
+	// the plain listener keeps serving everything, old gmod clients can't use https
+	if cfg.TLSAddr != "" {
+		tlsConfig, wrap, err := newTLSConfig(cfg)
+		if err != nil {
+			log.Fatalf("failed to set up tls: %s", err)
 		}
+
+		// serves acme http-01 challenges when using automatic certificates
+		plain = wrap(handler)
+
+		tl, err := net.Listen("tcp", cfg.TLSAddr)
+		if err != nil {
+			log.Fatalf("failed to create tls web server listener: %s", err)
+		}
+
+		tlsSrv := newServer(cfg, handler)
+		tlsSrv.TLSConfig = tlsConfig
+
+		servers = append(servers, tlsSrv)
+		go func() {
+			errs <- tlsSrv.ServeTLS(tl, "", "")
+		}()
+	}
+
+	// metrics are kept off the public listeners
+	if cfg.AdminAddr != "" {
+		admin := http.NewServeMux()
+		admin.Handle("GET /metrics", promhttp.Handler())
+		admin.HandleFunc("GET /healthz", ready.Healthz)
+		admin.HandleFunc("GET /readyz", ready.Readyz)
+
+		al, err := net.Listen("tcp", cfg.AdminAddr)
+		if err != nil {
+			log.Fatalf("failed to create admin web server listener: %s", err)
+		}
+
+		adminSrv := newServer(cfg, admin)
+
+		servers = append(servers, adminSrv)
+		go func() {
+			errs <- adminSrv.Serve(al)
+		}()
 	}
 
-	l, err := net.Listen(*proto, *addr)
+	l, err := listen(cfg.Proto, cfg.Addr)
 	if err != nil {
 		log.Fatalf("failed to create web server listener: %s", err)
 	}
 
-	defer l.Close()
+	srv := newServer(cfg, plain)
+
+	servers = append(servers, srv)
+	go func() {
+		errs <- srv.Serve(l)
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case err = <-errs:
+		log.Fatalf("web server failed: %s", err)
+	case <-ctx.Done():
+	}
+
+	// stop accepting connections and wait for in-flight requests (gma streams, uploads) to finish
+	log.Printf("shutting down, waiting up to %s for requests to finish", cfg.ShutdownTimeout)
+
+	sctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := srv.Shutdown(sctx)
+			if err != nil {
+				log.Printf("failed to shut down gracefully: %s", err)
+				srv.Close()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// deliver whatever events are still queued
+	err = bus.Close(sctx)
+	if err != nil {
+		log.Printf("failed to flush events: %s", err)
+	}
+
+	err = database.Close()
+	if err != nil {
+		log.Printf("failed to close database: %s", err)
+	}
+}
+
+func newServer(cfg *config.Config, handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+}
+
+// listen creates a tcp or unix socket listener
+// existing unix sockets are replaced and made accessible to everyone
+func listen(proto string, addr string) (net.Listener, error) {
+	if proto == "unix" {
+		err := os.Remove(addr)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to delete unix socket: %s", err)
+		}
+	}
+
+	l, err := net.Listen(proto, addr)
+	if err != nil {
+		return nil, err
+	}
 
-	if *proto == "unix" {
-		err = os.Chmod(*addr, 0777)
+	if proto == "unix" {
+		err = os.Chmod(addr, 0777)
 		if err != nil {
-			log.Fatalf("failed to set unix socket permissions: %s", err)
+			l.Close()
+			return nil, fmt.Errorf("failed to set unix socket permissions: %s", err)
 		}
 	}
 
-	http.Serve(l, nil)
+	return l, nil
 }
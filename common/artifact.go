@@ -0,0 +1,49 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package common
+
+import "time"
+
+// GMAArtifact is a built gma kept in the content store
+type GMAArtifact struct {
+	ID          int
+	Revision    int
+	Variant     string // "" for the package alone, "merge" with its includes merged in
+	Fingerprint string // hash of everything that went into the build
+	Key         string // content store object key
+	Size        int64
+	SHA256      string
+	Created     time.Time
+}
+
+// GMAManifest lists the files considered for a gma and whether the whitelist let them in
+type GMAManifest struct {
+	ID       int               `json:"id"`
+	Revision int               `json:"rev"`
+	Deps     string            `json:"deps,omitempty"` // merge or bundle if includes were taken along
+	Included []GMAManifestFile `json:"included"`
+	Excluded []GMAManifestFile `json:"excluded"`
+}
+
+type GMAManifestFile struct {
+	Package  int    `json:"package"`
+	Revision int    `json:"rev"`
+	Path     string `json:"path"`
+	Rule     string `json:"rule,omitempty"` // the whitelist pattern that decided, empty if none matched
+}
@@ -25,6 +25,9 @@ import (
 	"time"
 )
 
+// base url toybox clients download content from
+var ContentURL = "http://api.cl0udb0x.com"
+
 type Package struct {
 	ID       int       `json:"id"`
 	Revision int       `json:"rev"`
@@ -73,74 +76,74 @@ type Include struct {
 }
 
 func (pkg Package) Marshal(install bool) []byte {
-	script := make(VDF)
+	script := VDF{}
 
-	script["scriptid"] = pkg.ID
-	script["revision"] = pkg.Revision
-	script["type"] = pkg.Type
-	script["dataname"] = pkg.Dataname
-	script["name"] = pkg.Name
+	script.Add("scriptid", pkg.ID)
+	script.Add("revision", pkg.Revision)
+	script.Add("type", pkg.Type)
+	script.Add("dataname", pkg.Dataname)
+	script.Add("name", pkg.Name)
 
 	if install {
-		script["uid"] = pkg.UID()
+		script.Add("uid", pkg.UID())
 		if pkg.LuaMenuInstalled != "" {
-			script["luamenu_installed"] = pkg.LuaMenuInstalled
+			script.Add("luamenu_installed", pkg.LuaMenuInstalled)
 		}
 		if pkg.LuaMenuAction != "" {
-			script["luamenu_action"] = pkg.LuaMenuAction
+			script.Add("luamenu_action", pkg.LuaMenuAction)
 		}
 		if pkg.LuaClientInstalled != "" {
-			script["luaclient_installed"] = pkg.LuaClientInstalled
+			script.Add("luaclient_installed", pkg.LuaClientInstalled)
 		}
 		if pkg.LuaClientAction != "" {
-			script["luaclient_action"] = pkg.LuaClientAction
+			script.Add("luaclient_action", pkg.LuaClientAction)
 		}
 		if pkg.LuaServerInstalled != "" {
-			script["luaserver_installed"] = pkg.LuaServerInstalled
+			script.Add("luaserver_installed", pkg.LuaServerInstalled)
 		}
 		if pkg.LuaServerAction != "" {
-			script["luaserver_action"] = pkg.LuaServerAction
+			script.Add("luaserver_action", pkg.LuaServerAction)
 		}
 	}
 
 	if len(pkg.Content) != 0 {
-		content := make(VDF)
+		content := VDF{}
 
 		for _, c := range pkg.Content {
-			item := make(VDF)
+			item := VDF{}
 
-			item["id"] = c.ID
-			item["rev"] = c.Revision
-			item["name"] = c.Path
-			item["url"] = fmt.Sprintf("http://api.cl0udb0x.com/content/getzip?id=%d", c.ID)
-			item["size"] = c.PSize
+			item.Add("id", c.ID)
+			item.Add("rev", c.Revision)
+			item.Add("name", c.Path)
+			item.Add("url", fmt.Sprintf("%s/content/getzip?id=%d", strings.TrimSuffix(ContentURL, "/"), c.ID))
+			item.Add("size", c.PSize)
 
 			// name doesn't matter
-			content[fmt.Sprintf("content_%d", c.ID)] = item
+			content.Add(fmt.Sprintf("content_%d", c.ID), item)
 		}
 
-		script["content"] = content
+		script.Add("content", content)
 	}
 
 	if len(pkg.Includes) != 0 {
-		includes := make(VDF)
+		includes := VDF{}
 
 		for _, i := range pkg.Includes {
-			item := make(VDF)
+			item := VDF{}
 
-			item["id"] = i.ID
-			item["rev"] = i.Revision
-			item["type"] = i.Type
+			item.Add("id", i.ID)
+			item.Add("rev", i.Revision)
+			item.Add("type", i.Type)
 
 			// name doesn't matter
-			includes[fmt.Sprintf("include_%d", i.ID)] = item
+			includes.Add(fmt.Sprintf("include_%d", i.ID), item)
 		}
 
-		script["includes"] = includes
+		script.Add("includes", includes)
 	}
 
-	root := make(VDF)
-	root["script"] = script
+	root := VDF{}
+	root.Add("script", script)
 
 	if len(pkg.Data) != 0 {
 		return append([]byte(root.Marshal()), pkg.Data...)
@@ -18,31 +18,489 @@
 
 package common
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"path"
+	"strconv"
+	"strings"
+)
 
-type VDF map[string]any
+// KeyValue is one entry of a VDF block
+// Value is a string, an int or a nested VDF, binary KeyValues may also hold
+// float32, uint64, KVPointer and color.RGBA values
+type KeyValue struct {
+	Key   string
+	Value any
+}
+
+// VDF is a KeyValues block, entries keep the order they were added in
+// keys may repeat, Source's own KeyValues allows it too
+type VDF []KeyValue
+
+func (vdf *VDF) Add(key string, value any) {
+	*vdf = append(*vdf, KeyValue{Key: key, Value: value})
+}
+
+// Get returns the value of the first entry named key, keys are case insensitive
+func (vdf VDF) Get(key string) (any, bool) {
+	for _, kv := range vdf {
+		if strings.EqualFold(kv.Key, key) {
+			return kv.Value, true
+		}
+	}
+
+	return nil, false
+}
+
+// GetString returns the value of key as a string, or "" if it's missing or a block
+func (vdf VDF) GetString(key string) string {
+	v, _ := vdf.Get(key)
+	s, _ := formatVDFValue(v)
+
+	return s
+}
+
+// GetVDF returns the block named key, or nil if it's missing or not a block
+func (vdf VDF) GetVDF(key string) VDF {
+	v, _ := vdf.Get(key)
+	data, _ := v.(VDF)
+
+	return data
+}
 
 func (vdf VDF) Marshal() string {
-	var output string
+	var output strings.Builder
 
 	vdf.encode(&output)
+	output.WriteString("\n")
 
-	return output + "\n"
+	return output.String()
 }
 
-// very crude VDF string encoder specifically targeting toybox's implementation
+// VDF string encoder targeting toybox's implementation
 // all values become strings, even though VDF supports non-string values
-func (vdf VDF) encode(output *string) {
-	for k, v := range vdf {
-		switch data := v.(type) {
-		case int:
-			*output += fmt.Sprintf("\"%s\"\t\"%d\"\n", k, data)
-		case string:
-			*output += fmt.Sprintf("\"%s\"\t\"%s\"\n", k, data)
-		case VDF:
-			*output += fmt.Sprintf("\"%s\"\n{\n", k)
+func (vdf VDF) encode(output *strings.Builder) {
+	for _, kv := range vdf {
+		if data, ok := kv.Value.(VDF); ok {
+			fmt.Fprintf(output, "\"%s\"\n{\n", escapeVDF(kv.Key))
 			data.encode(output)
-			*output += "}\n"
+			output.WriteString("}\n")
+			continue
+		}
+
+		if s, ok := formatVDFValue(kv.Value); ok {
+			fmt.Fprintf(output, "\"%s\"\t\"%s\"\n", escapeVDF(kv.Key), escapeVDF(s))
+		}
+	}
+}
+
+// formatVDFValue formats a non-block value the way Source's text KeyValues does
+func formatVDFValue(v any) (string, bool) {
+	switch data := v.(type) {
+	case string:
+		return data, true
+	case int:
+		return strconv.Itoa(data), true
+	case float32:
+		return strconv.FormatFloat(float64(data), 'f', -1, 32), true
+	case uint64:
+		return strconv.FormatUint(data, 10), true
+	case KVPointer:
+		return strconv.FormatUint(uint64(data), 10), true
+	case color.RGBA:
+		return fmt.Sprintf("%d %d %d %d", data.R, data.G, data.B, data.A), true
+	}
+
+	return "", false
+}
+
+// newlines are left alone, quoted strings may span lines
+var vdfEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func escapeVDF(s string) string {
+	return vdfEscaper.Replace(s)
+}
+
+// ParseVDF parses text KeyValues with no symbols defined and no way to open files
+//
+// #include and #base directives are skipped and every conditional symbol is false,
+// so [$X] drops its key and [!$X] keeps it.
+// use a VDFParser with Open and Conditions set to resolve them
+func ParseVDF(data []byte) (VDF, error) {
+	return (&VDFParser{}).Parse("vdf", data)
+}
+
+// VDFParser reads text KeyValues
+//
+// quoted and unquoted tokens, // comments, the \n \t \\ and \" escapes,
+// #include/#base directives and [$X], [!$X], [$X||$Y] and [$X&&$Y] conditionals are supported
+type VDFParser struct {
+	// Open resolves #include and #base file names, nil skips the directives
+	Open func(name string) ([]byte, error)
+
+	// Conditions holds the symbols conditionals test for, such as WIN32 or LINUX
+	// symbols that aren't set are false
+	Conditions map[string]bool
+
+	depth int
+}
+
+const vdfMaxIncludeDepth = 16
+
+// Parse parses data, name is used for errors and relative include paths
+func (p *VDFParser) Parse(name string, data []byte) (VDF, error) {
+	// editors on windows like to add a byte order mark
+	l := &vdfLexer{name: name, data: strings.TrimPrefix(string(data), "\ufeff"), line: 1}
+
+	root, err := p.parseBlock(l, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return root, nil
+}
+
+func (p *VDFParser) parseBlock(l *vdfLexer, top bool) (VDF, error) {
+	var vdf VDF
+	var includes, bases []VDF
+
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok.kind {
+		case vdfEOF:
+			if !top {
+				return nil, l.errorf("unexpected end of file, missing }")
+			}
+
+			// like Source, included keys go after the file's own and bases only fill in what's missing
+			for _, included := range includes {
+				vdf = append(vdf, included...)
+			}
+
+			for _, base := range bases {
+				vdf = mergeVDF(vdf, base)
+			}
+
+			return vdf, nil
+		case vdfClose:
+			if top {
+				return nil, l.errorf("unexpected }")
+			}
+
+			return vdf, nil
+		case vdfOpen, vdfCondition:
+			return nil, l.errorf("expected a key, got %s", tok.text)
+		}
+
+		key := tok.text
+
+		// directives are unquoted keys at the top level
+		if top && !tok.quoted && (key == "#include" || key == "#base") {
+			file, err := l.next()
+			if err != nil {
+				return nil, err
+			}
+
+			if file.kind != vdfString {
+				return nil, l.errorf("expected a file name after %s", key)
+			}
+
+			included, err := p.include(l.name, file.text)
+			if err != nil {
+				return nil, l.errorf("%s %q: %s", key, file.text, err)
+			}
+
+			if key == "#include" {
+				includes = append(includes, included)
+			} else {
+				bases = append(bases, included)
+			}
+
+			continue
+		}
+
+		tok, err = l.next()
+		if err != nil {
+			return nil, err
+		}
+
+		include := true
+		if tok.kind == vdfCondition {
+			include, err = p.evaluate(tok.text)
+			if err != nil {
+				return nil, l.errorf("%s", err)
+			}
+
+			tok, err = l.next()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		switch tok.kind {
+		case vdfOpen:
+			block, err := p.parseBlock(l, false)
+			if err != nil {
+				return nil, err
+			}
+
+			if include {
+				vdf.Add(key, block)
+			}
+		case vdfString:
+			value := tok.text
+
+			// conditions usually follow the value
+			next, err := l.peek()
+			if err != nil {
+				return nil, err
+			}
+
+			if next.kind == vdfCondition {
+				l.next()
+
+				ok, err := p.evaluate(next.text)
+				if err != nil {
+					return nil, l.errorf("%s", err)
+				}
+
+				include = include && ok
+			}
+
+			if include {
+				vdf.Add(key, value)
+			}
+		default:
+			return nil, l.errorf("expected a value or { after %q", key)
+		}
+	}
+}
+
+func (p *VDFParser) include(from string, name string) (VDF, error) {
+	if p.Open == nil {
+		return nil, nil
+	}
+
+	if p.depth >= vdfMaxIncludeDepth {
+		return nil, errors.New("includes nested too deeply")
+	}
+
+	// names are relative to the including file
+	name = path.Join(path.Dir(from), name)
+
+	data, err := p.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	p.depth++
+	defer func() { p.depth-- }()
+
+	return p.Parse(name, data)
+}
+
+// evaluate checks a conditional such as [!$X360&&$WIN32]
+func (p *VDFParser) evaluate(cond string) (bool, error) {
+	expr := strings.TrimSuffix(strings.TrimPrefix(cond, "["), "]")
+
+	for _, or := range strings.Split(expr, "||") {
+		ok := true
+		for _, term := range strings.Split(or, "&&") {
+			term = strings.TrimSpace(term)
+
+			negate := strings.HasPrefix(term, "!")
+			term = strings.TrimPrefix(term, "!")
+
+			if !strings.HasPrefix(term, "$") || len(term) == 1 {
+				return false, fmt.Errorf("invalid conditional %s", cond)
+			}
+
+			if p.Conditions[strings.ToUpper(term[1:])] == negate {
+				ok = false
+			}
+		}
+
+		if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// mergeVDF adds the entries of base that vdf doesn't have, blocks present in both are merged
+func mergeVDF(vdf VDF, base VDF) VDF {
+	for _, kv := range base {
+		existing, ok := vdf.Get(kv.Key)
+		if !ok {
+			vdf.Add(kv.Key, kv.Value)
+			continue
+		}
+
+		eb, ok1 := existing.(VDF)
+		bb, ok2 := kv.Value.(VDF)
+		if ok1 && ok2 {
+			for i := range vdf {
+				if strings.EqualFold(vdf[i].Key, kv.Key) {
+					vdf[i].Value = mergeVDF(eb, bb)
+					break
+				}
+			}
+		}
+	}
+
+	return vdf
+}
+
+type vdfTokenKind int
+
+const (
+	vdfEOF vdfTokenKind = iota
+	vdfString
+	vdfOpen
+	vdfClose
+	vdfCondition
+)
+
+type vdfToken struct {
+	kind   vdfTokenKind
+	text   string
+	quoted bool
+}
+
+type vdfLexer struct {
+	name string
+	data string
+	pos  int
+	line int
+
+	peeked *vdfToken
+}
+
+func (l *vdfLexer) errorf(format string, args ...any) error {
+	return fmt.Errorf("%s:%d: %s", l.name, l.line, fmt.Sprintf(format, args...))
+}
+
+func (l *vdfLexer) peek() (vdfToken, error) {
+	if l.peeked == nil {
+		tok, err := l.next()
+		if err != nil {
+			return vdfToken{}, err
+		}
+
+		l.peeked = &tok
+	}
+
+	return *l.peeked, nil
+}
+
+func (l *vdfLexer) next() (vdfToken, error) {
+	if l.peeked != nil {
+		tok := *l.peeked
+		l.peeked = nil
+
+		return tok, nil
+	}
+
+	l.skip()
+
+	if l.pos >= len(l.data) {
+		return vdfToken{kind: vdfEOF}, nil
+	}
+
+	switch c := l.data[l.pos]; c {
+	case '{':
+		l.pos++
+		return vdfToken{kind: vdfOpen, text: "{"}, nil
+	case '}':
+		l.pos++
+		return vdfToken{kind: vdfClose, text: "}"}, nil
+	case '[':
+		end := strings.IndexAny(l.data[l.pos:], "]\n")
+		if end == -1 || l.data[l.pos+end] != ']' {
+			return vdfToken{}, l.errorf("unterminated conditional")
+		}
+
+		text := l.data[l.pos : l.pos+end+1]
+		l.pos += end + 1
+
+		return vdfToken{kind: vdfCondition, text: text}, nil
+	case '"':
+		return l.quoted()
+	}
+
+	start := l.pos
+	for l.pos < len(l.data) && !strings.ContainsRune(" \t\r\n\"{}", rune(l.data[l.pos])) {
+		l.pos++
+	}
+
+	return vdfToken{kind: vdfString, text: l.data[start:l.pos]}, nil
+}
+
+// quoted reads a quoted string, the lexer is positioned on the opening quote
+func (l *vdfLexer) quoted() (vdfToken, error) {
+	l.pos++
+
+	var sb strings.Builder
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+
+		switch c {
+		case '"':
+			return vdfToken{kind: vdfString, text: sb.String(), quoted: true}, nil
+		case '\n':
+			l.line++
+		case '\\':
+			if l.pos < len(l.data) {
+				e := l.data[l.pos]
+				switch e {
+				case 'n':
+					c = '\n'
+				case 't':
+					c = '\t'
+				case '\\', '"':
+					c = e
+				default:
+					// not an escape, keep the backslash
+					sb.WriteByte('\\')
+					continue
+				}
+
+				l.pos++
+			}
+		}
+
+		sb.WriteByte(c)
+	}
+
+	return vdfToken{}, l.errorf("unterminated string")
+}
+
+// skip moves past whitespace and // comments
+func (l *vdfLexer) skip() {
+	for l.pos < len(l.data) {
+		switch {
+		case l.data[l.pos] == '\n':
+			l.line++
+			l.pos++
+		case strings.ContainsRune(" \t\r", rune(l.data[l.pos])):
+			l.pos++
+		case strings.HasPrefix(l.data[l.pos:], "//"):
+			end := strings.IndexByte(l.data[l.pos:], '\n')
+			if end == -1 {
+				l.pos = len(l.data)
+			} else {
+				l.pos += end
+			}
+		default:
+			return
 		}
 	}
 }
@@ -0,0 +1,226 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package common
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVDFRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		vdf  VDF
+	}{
+		{"empty", nil},
+		{"flat", VDF{{"name", "Test Map"}, {"dataname", "gm_test"}}},
+		{"nested", VDF{{"info", VDF{{"type", "map"}, {"content", VDF{{"1", "maps/gm_test.bsp"}}}}}}},
+		{"empty block", VDF{{"content", VDF(nil)}}},
+		{"quotes", VDF{{`say "hi"`, `he said "hello"`}}},
+		{"backslashes", VDF{{"path", `C:\garrysmod\maps`}, {"escape-like", `\n\t\"`}}},
+		{"newlines", VDF{{"description", "line one\nline two\n\ttabbed"}}},
+		{"comment-like", VDF{{"url", "http://example.com//path"}, {"//", "not a comment"}}},
+		{"braces", VDF{{"{", "}"}, {"[$WIN32]", "[!$X360]"}}},
+		{"repeated keys keep order", VDF{{"b", "1"}, {"a", "2"}, {"b", "3"}, {"A", "4"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := tt.vdf.Marshal()
+
+			got, err := ParseVDF([]byte(text))
+			if err != nil {
+				t.Fatalf("failed to parse %q: %s", text, err)
+			}
+
+			if !reflect.DeepEqual(got, tt.vdf) {
+				t.Fatalf("round trip of %q\ngot  %#v\nwant %#v", text, got, tt.vdf)
+			}
+		})
+	}
+}
+
+func TestVDFMarshal(t *testing.T) {
+	vdf := VDF{
+		{"name", `a "quoted" \ name`},
+		{"revision", 3},
+		{"info", VDF{{"type", "map"}}},
+	}
+
+	want := "\"name\"\t\"a \\\"quoted\\\" \\\\ name\"\n" +
+		"\"revision\"\t\"3\"\n" +
+		"\"info\"\n{\n\"type\"\t\"map\"\n}\n" +
+		"\n"
+
+	if got := vdf.Marshal(); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestParseVDF(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want VDF
+	}{
+		{
+			name: "unquoted tokens",
+			text: "info { type map revision 3 }",
+			want: VDF{{"info", VDF{{"type", "map"}, {"revision", "3"}}}},
+		},
+		{
+			name: "comments",
+			text: "// header\n\"a\" \"1\" // trailing\n// \"b\" \"2\"\n\"c\" // between\n\"3\"",
+			want: VDF{{"a", "1"}, {"c", "3"}},
+		},
+		{
+			name: "escapes",
+			text: `"a" "tab\there" "b" "line\nbreak" "c" "\\\"" "d" "\q"`,
+			want: VDF{{"a", "tab\there"}, {"b", "line\nbreak"}, {"c", `\"`}, {"d", `\q`}},
+		},
+		{
+			name: "byte order mark",
+			text: "\ufeff\"a\" \"1\"",
+			want: VDF{{"a", "1"}},
+		},
+		{
+			name: "conditionals default to false",
+			text: "\"a\" \"1\" [$WIN32]\n\"b\" \"2\" [!$WIN32]\n\"c\" [$X360] { \"d\" \"3\" }\n\"e\" \"4\" [$WIN32||!$OSX]",
+			want: VDF{{"b", "2"}, {"e", "4"}},
+		},
+		{
+			name: "directives are skipped",
+			text: "#base \"base.vdf\"\n#include \"other.vdf\"\n\"a\" \"1\"",
+			want: VDF{{"a", "1"}},
+		},
+		{
+			name: "quoted directives are keys",
+			text: `"#base" "base.vdf"`,
+			want: VDF{{"#base", "base.vdf"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseVDF([]byte(tt.text))
+			if err != nil {
+				t.Fatalf("failed to parse: %s", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseVDFErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"missing close", `"a" { "b" "c"`, "missing }"},
+		{"extra close", `"a" "b" }`, "unexpected }"},
+		{"unterminated string", `"a" "b`, "unterminated string"},
+		{"unterminated conditional", "\"a\" \"b\" [$WIN32\n", "unterminated conditional"},
+		{"invalid conditional", `"a" "b" [WIN32]`, "invalid conditional"},
+		{"missing value", `"a"`, "expected a value"},
+		{"block as key", `{ "a" "b" }`, "expected a key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseVDF([]byte(tt.text))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVDFParserConditions(t *testing.T) {
+	text := `"a" "1" [$WIN32]
+"b" "2" [!$WIN32]
+"c" [$linux] { "d" "3" }
+"e" "4" [$X360||$WIN32]
+"f" "5" [$WIN32&&!$LINUX]
+"g" "6" [$WIN32&&$LINUX]`
+
+	p := &VDFParser{Conditions: map[string]bool{"WIN32": true}}
+
+	got, err := p.Parse("test.vdf", []byte(text))
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+
+	want := VDF{{"a", "1"}, {"e", "4"}, {"f", "5"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+}
+
+func TestVDFParserIncludes(t *testing.T) {
+	files := map[string]string{
+		"scripts/main.vdf": "#base \"base.vdf\"\n#include \"extra/more.vdf\"\n\"a\" \"main\"\n\"block\" { \"x\" \"main\" }",
+		"scripts/base.vdf": "\"a\" \"base\"\n\"b\" \"base\"\n\"block\" { \"x\" \"base\" \"y\" \"base\" }",
+		// relative to the including file, not the root
+		"scripts/extra/more.vdf":   "#include \"deeper.vdf\"\n\"c\" \"more\"",
+		"scripts/extra/deeper.vdf": "\"d\" \"deeper\"",
+		"scripts/loop.vdf":         "#include \"loop.vdf\"",
+	}
+
+	p := &VDFParser{Open: func(name string) ([]byte, error) {
+		data, ok := files[name]
+		if !ok {
+			return nil, errors.New("not found")
+		}
+
+		return []byte(data), nil
+	}}
+
+	got, err := p.Parse("scripts/main.vdf", []byte(files["scripts/main.vdf"]))
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+
+	want := VDF{
+		{"a", "main"},
+		{"block", VDF{{"x", "main"}, {"y", "base"}}},
+		{"c", "more"},
+		{"d", "deeper"},
+		{"b", "base"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+
+	_, err = p.Parse("scripts/loop.vdf", []byte(files["scripts/loop.vdf"]))
+	if err == nil || !strings.Contains(err.Error(), "nested too deeply") {
+		t.Fatalf("got error %v for an include loop", err)
+	}
+
+	_, err = p.Parse("scripts/missing.vdf", []byte(`#include "nope.vdf"`))
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("got error %v for a missing include", err)
+	}
+}
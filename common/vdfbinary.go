@@ -0,0 +1,262 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strings"
+)
+
+// binary KeyValues value types, as in Source's KeyValues::types_t
+const (
+	kvTypeNone    = 0 // nested block
+	kvTypeString  = 1
+	kvTypeInt     = 2
+	kvTypeFloat   = 3
+	kvTypePtr     = 4
+	kvTypeWString = 5
+	kvTypeColor   = 6
+	kvTypeUint64  = 7
+	kvTypeEnd     = 8
+)
+
+const (
+	kvMaxDepth  = 64
+	kvMaxString = 1 << 20
+)
+
+// KVPointer is a binary KeyValues pointer value, only meaningful to the process that wrote it
+type KVPointer uint32
+
+// ReadBinaryVDF decodes binary KeyValues, stopping after the end marker of the outermost level
+//
+// blocks become VDF, strings string, ints int, floats float32, pointers KVPointer,
+// colors color.RGBA and uint64s uint64
+// wide strings become "", Source writes them without a value
+// r is read through a buffer unless it's an io.ByteReader, so it may be read past the end marker
+func ReadBinaryVDF(r io.Reader) (VDF, error) {
+	br, ok := r.(binaryReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+
+	return readBinaryVDF(br, 0, true)
+}
+
+type binaryReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+func readBinaryVDF(r binaryReader, depth int, top bool) (VDF, error) {
+	if depth > kvMaxDepth {
+		return nil, errors.New("binary vdf nested too deeply")
+	}
+
+	var vdf VDF
+	for {
+		t, err := r.ReadByte()
+		if err != nil {
+			// a stream may simply end without a final end marker
+			if err == io.EOF && top {
+				return vdf, nil
+			}
+
+			return nil, unexpectedEOF(err)
+		}
+
+		if t == kvTypeEnd {
+			return vdf, nil
+		}
+
+		key, err := readCString(r)
+		if err != nil {
+			return nil, err
+		}
+
+		var value any
+		switch t {
+		case kvTypeNone:
+			value, err = readBinaryVDF(r, depth+1, false)
+		case kvTypeString:
+			value, err = readCString(r)
+		case kvTypeInt:
+			var v int32
+			err = binary.Read(r, binary.LittleEndian, &v)
+			value = int(v)
+		case kvTypeFloat:
+			var v float32
+			err = binary.Read(r, binary.LittleEndian, &v)
+			value = v
+		case kvTypePtr:
+			var v uint32
+			err = binary.Read(r, binary.LittleEndian, &v)
+			value = KVPointer(v)
+		case kvTypeWString:
+			value = ""
+		case kvTypeColor:
+			var v [4]byte
+			_, err = io.ReadFull(r, v[:])
+			value = color.RGBA{R: v[0], G: v[1], B: v[2], A: v[3]}
+		case kvTypeUint64:
+			var v uint64
+			err = binary.Read(r, binary.LittleEndian, &v)
+			value = v
+		default:
+			return nil, fmt.Errorf("unknown binary vdf type %d for key %q", t, key)
+		}
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+
+		vdf.Add(key, value)
+	}
+}
+
+func readCString(r io.ByteReader) (string, error) {
+	var b []byte
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return "", unexpectedEOF(err)
+		}
+
+		if c == 0 {
+			return string(b), nil
+		}
+
+		if len(b) >= kvMaxString {
+			return "", errors.New("binary vdf string too long")
+		}
+
+		b = append(b, c)
+	}
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+// WriteBinaryVDF encodes vdf as binary KeyValues, including the final end marker
+func WriteBinaryVDF(w io.Writer, vdf VDF) error {
+	bw := bufio.NewWriter(w)
+
+	err := writeBinaryVDF(bw, vdf, 0)
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+func writeBinaryVDF(w *bufio.Writer, vdf VDF, depth int) error {
+	if depth > kvMaxDepth {
+		return errors.New("vdf nested too deeply")
+	}
+
+	for _, kv := range vdf {
+		if strings.ContainsRune(kv.Key, 0) {
+			return fmt.Errorf("key %q contains a null byte", kv.Key)
+		}
+
+		if s, ok := kv.Value.(string); ok && strings.ContainsRune(s, 0) {
+			return fmt.Errorf("value of %q contains a null byte", kv.Key)
+		}
+
+		var t byte
+		var payload []byte
+		switch v := kv.Value.(type) {
+		case VDF:
+			t = kvTypeNone
+		case string:
+			t = kvTypeString
+			payload = append([]byte(v), 0)
+		case int:
+			if v < math.MinInt32 || v > math.MaxInt32 {
+				return fmt.Errorf("value of %q doesn't fit in 32 bits", kv.Key)
+			}
+
+			t = kvTypeInt
+			payload = binary.LittleEndian.AppendUint32(nil, uint32(int32(v)))
+		case float32:
+			t = kvTypeFloat
+			payload = binary.LittleEndian.AppendUint32(nil, math.Float32bits(v))
+		case KVPointer:
+			t = kvTypePtr
+			payload = binary.LittleEndian.AppendUint32(nil, uint32(v))
+		case color.RGBA:
+			t = kvTypeColor
+			payload = []byte{v.R, v.G, v.B, v.A}
+		case uint64:
+			t = kvTypeUint64
+			payload = binary.LittleEndian.AppendUint64(nil, v)
+		default:
+			return fmt.Errorf("unsupported value type %T for %q", kv.Value, kv.Key)
+		}
+
+		w.WriteByte(t)
+		w.WriteString(kv.Key)
+		w.WriteByte(0)
+		w.Write(payload)
+
+		if block, ok := kv.Value.(VDF); ok {
+			err := writeBinaryVDF(w, block, depth+1)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return w.WriteByte(kvTypeEnd)
+}
+
+// MarshalBinary encodes vdf as binary KeyValues
+func (vdf VDF) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+
+	err := WriteBinaryVDF(&buf, vdf)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes binary KeyValues into vdf
+func (vdf *VDF) UnmarshalBinary(data []byte) error {
+	v, err := ReadBinaryVDF(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	*vdf = v
+
+	return nil
+}
@@ -0,0 +1,178 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package common
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBinaryVDFRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		vdf  VDF
+	}{
+		{"empty", nil},
+		{"strings", VDF{{"name", "Test Map"}, {"empty", ""}, {"utf8", "ünïcödé"}}},
+		{"ints", VDF{{"zero", 0}, {"min", math.MinInt32}, {"max", math.MaxInt32}}},
+		{"floats", VDF{{"pi", float32(3.14159)}, {"negative", float32(-0.5)}}},
+		{"pointer", VDF{{"ptr", KVPointer(0xdeadbeef)}}},
+		{"color", VDF{{"color", color.RGBA{R: 255, G: 128, B: 0, A: 64}}}},
+		{"uint64", VDF{{"steamid", uint64(76561197960287930)}, {"max", uint64(math.MaxUint64)}}},
+		{"nested", VDF{{"a", VDF{{"b", VDF{{"c", "deep"}}}, {"after", 1}}}, {"empty", VDF(nil)}}},
+		{"repeated keys keep order", VDF{{"b", "1"}, {"a", 2}, {"b", "3"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.vdf.MarshalBinary()
+			if err != nil {
+				t.Fatalf("failed to marshal: %s", err)
+			}
+
+			var got VDF
+			err = got.UnmarshalBinary(data)
+			if err != nil {
+				t.Fatalf("failed to unmarshal %x: %s", data, err)
+			}
+
+			if !reflect.DeepEqual(got, tt.vdf) {
+				t.Fatalf("round trip of %x\ngot  %#v\nwant %#v", data, got, tt.vdf)
+			}
+		})
+	}
+}
+
+func TestBinaryVDFFormat(t *testing.T) {
+	vdf := VDF{
+		{"info", VDF{{"name", "x"}, {"rev", 3}}},
+	}
+
+	want := []byte("\x00info\x00" +
+		"\x01name\x00x\x00" +
+		"\x02rev\x00\x03\x00\x00\x00" +
+		"\x08" +
+		"\x08")
+
+	got, err := vdf.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal: %s", err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestReadBinaryVDF(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want VDF
+	}{
+		{"no end marker", "\x01a\x00b\x00", VDF{{"a", "b"}}},
+		{"wide strings have no value", "\x05w\x00\x01a\x00b\x00\x08", VDF{{"w", ""}, {"a", "b"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadBinaryVDF(strings.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("failed to read: %s", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadBinaryVDFStopsAtEnd(t *testing.T) {
+	r := bytes.NewReader([]byte("\x01a\x00b\x00\x08trailing"))
+
+	_, err := ReadBinaryVDF(r)
+	if err != nil {
+		t.Fatalf("failed to read: %s", err)
+	}
+
+	rest, _ := io.ReadAll(r)
+	if string(rest) != "trailing" {
+		t.Fatalf("got %q after the end marker", rest)
+	}
+}
+
+func TestReadBinaryVDFErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want error
+	}{
+		{"truncated key", "\x01ab", io.ErrUnexpectedEOF},
+		{"truncated int", "\x02a\x00\x01\x02", io.ErrUnexpectedEOF},
+		{"unterminated block", "\x00a\x00\x01b\x00c\x00", io.ErrUnexpectedEOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadBinaryVDF(strings.NewReader(tt.data))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got error %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	_, err := ReadBinaryVDF(strings.NewReader("\x09a\x00"))
+	if err == nil || !strings.Contains(err.Error(), "unknown binary vdf type 9") {
+		t.Fatalf("got error %v for an unknown type", err)
+	}
+
+	_, err = ReadBinaryVDF(strings.NewReader(strings.Repeat("\x00a\x00", kvMaxDepth+2)))
+	if err == nil || !strings.Contains(err.Error(), "nested too deeply") {
+		t.Fatalf("got error %v for deep nesting", err)
+	}
+}
+
+func TestWriteBinaryVDFErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		vdf  VDF
+		want string
+	}{
+		{"null in key", VDF{{"a\x00b", "c"}}, "null byte"},
+		{"null in value", VDF{{"a", "b\x00c"}}, "null byte"},
+		{"int too big", VDF{{"a", math.MaxInt32 + 1}}, "32 bits"},
+		{"unsupported type", VDF{{"a", 1.5}}, "unsupported value type float64"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.vdf.MarshalBinary()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
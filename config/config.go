@@ -0,0 +1,462 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flatgrassdotnet/cloudbox/events"
+)
+
+// values of these flags are replaced when printing the config
+var secrets = []string{"dbpass", "apikey", "statswebhook", "savewebhook"}
+
+// sinkList collects repeated -sink flags
+type sinkList []events.SinkConfig
+
+func (l *sinkList) String() string {
+	var specs []string
+	for _, c := range *l {
+		specs = append(specs, c.String())
+	}
+
+	return strings.Join(specs, "\n")
+}
+
+// reset forgets the sinks from a lower precedence source
+func (l *sinkList) reset() {
+	*l = nil
+}
+
+// Set adds one sink, or one per line (for CLOUDBOX_SINK)
+func (l *sinkList) Set(value string) error {
+	for _, spec := range strings.Split(value, "\n") {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+
+		c, err := events.ParseSinkConfig(spec)
+		if err != nil {
+			return err
+		}
+
+		*l = append(*l, c)
+	}
+
+	return nil
+}
+
+type Config struct {
+	// database
+	DBDriver string
+	DBPath   string
+	DBUser   string
+	DBPass   string
+	DBProto  string
+	DBAddr   string
+	DBName   string
+
+	// storage
+	Storage       string
+	StorageDir    string
+	ContentBucket string
+	ImageBucket   string
+	ContentURL    string
+	ImageURL      string
+
+	// integrations
+	APIKey       string
+	StatsWebhook string
+	SaveWebhook  string
+	DiscordQueue int
+	Sinks        sinkList
+
+	// web server
+	Proto string
+	Addr  string
+
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	ShutdownTimeout   time.Duration
+
+	// optional https listener
+	TLSAddr   string
+	TLSCert   string
+	TLSKey    string
+	ACMEDir   string
+	ACMEHosts string
+	ACMEEmail string
+
+	// admin listener for /metrics, /healthz and /readyz
+	AdminAddr    string
+	ReadyTimeout time.Duration
+	FailFast     bool
+
+	// logging
+	LogLevel  string
+	LogFormat string
+
+	File        string
+	PrintConfig bool
+
+	fs *flag.FlagSet
+}
+
+func (c *Config) registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.DBDriver, "dbdriver", "mysql", "database driver (mysql or sqlite)")
+	fs.StringVar(&c.DBPath, "dbpath", "cloudbox.db", "sqlite database file")
+	fs.StringVar(&c.DBUser, "dbuser", "cloudbox", "database user's name")
+	fs.StringVar(&c.DBPass, "dbpass", "", "database user's password")
+	fs.StringVar(&c.DBProto, "dbproto", "tcp", "database connection protocol")
+	fs.StringVar(&c.DBAddr, "dbaddr", "localhost", "database server address")
+	fs.StringVar(&c.DBName, "dbname", "cloudbox", "database name")
+	fs.StringVar(&c.Storage, "storage", "s3", "content storage backend (s3 or local)")
+	fs.StringVar(&c.StorageDir, "storagedir", "data/storage", "directory for the local storage backend")
+	fs.StringVar(&c.ContentBucket, "contentbucket", "flatgrass-toybox-content", "content storage bucket name")
+	fs.StringVar(&c.ImageBucket, "imagebucket", "flatgrass-toybox-image", "image storage bucket name")
+	fs.StringVar(&c.ContentURL, "contenturl", "http://api.cl0udb0x.com", "base url clients download content from")
+	fs.StringVar(&c.ImageURL, "imageurl", "https://img.cl0udb0x.com", "base url of the public image bucket")
+	fs.StringVar(&c.APIKey, "apikey", "", "steam web api key")
+	fs.StringVar(&c.StatsWebhook, "statswebhook", "", "discord stats webhook url")
+	fs.StringVar(&c.SaveWebhook, "savewebhook", "", "discord save webhook url")
+	fs.IntVar(&c.DiscordQueue, "discordqueue", 1000, "maximum number of discord embeds waiting to be sent per webhook")
+	fs.Var(&c.Sinks, "sink", "additional event sink as \"type=discord|webhook|slack|matrix|file url=... secret=... path=... events=login+map_load\", may be repeated")
+	fs.StringVar(&c.Proto, "proto", "tcp", "proto for web server")
+	fs.StringVar(&c.Addr, "addr", "127.0.0.1:80", "address for web server")
+	fs.DurationVar(&c.ReadTimeout, "readtimeout", time.Minute, "maximum time to read a request including its body, 0 for none")
+	fs.DurationVar(&c.ReadHeaderTimeout, "readheadertimeout", 10*time.Second, "maximum time to read request headers")
+	fs.DurationVar(&c.WriteTimeout, "writetimeout", 0, "maximum time to write a response, 0 for none (large gma downloads can take a while)")
+	fs.DurationVar(&c.IdleTimeout, "idletimeout", 2*time.Minute, "maximum time to keep idle keep-alive connections open")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdowntimeout", 30*time.Second, "maximum time to wait for in-flight requests on shutdown")
+	fs.StringVar(&c.TLSAddr, "tlsaddr", "", "address for the https web server, empty to disable")
+	fs.StringVar(&c.TLSCert, "tlscert", "", "tls certificate file")
+	fs.StringVar(&c.TLSKey, "tlskey", "", "tls private key file")
+	fs.StringVar(&c.ACMEDir, "acmedir", "", "directory to cache acme certificates in, used when tlscert isn't set")
+	fs.StringVar(&c.ACMEHosts, "acmehosts", "", "comma separated host names to request acme certificates for")
+	fs.StringVar(&c.ACMEEmail, "acmeemail", "", "contact email for the acme account")
+	fs.StringVar(&c.AdminAddr, "adminaddr", "", "address for the admin web server serving /metrics (and /healthz and /readyz, which the main one serves too), empty to disable")
+	fs.DurationVar(&c.ReadyTimeout, "readytimeout", 5*time.Second, "maximum time the readiness checks may take")
+	fs.BoolVar(&c.FailFast, "failfast", false, "exit at startup if the database, storage or steam api key isn't usable")
+	fs.StringVar(&c.LogLevel, "loglevel", "info", "minimum log level (debug, info, warn or error)")
+	fs.StringVar(&c.LogFormat, "logformat", "text", "log output format (text or json)")
+	fs.StringVar(&c.File, "config", "", "path to a json config file")
+	fs.BoolVar(&c.PrintConfig, "print-config", false, "print the effective config with secrets redacted and exit")
+}
+
+// Parse loads the config from flags, environment variables and a config file
+// flags take precedence over CLOUDBOX_* environment variables, which take precedence over the file
+func Parse(fs *flag.FlagSet, args []string) (*Config, error) {
+	c := &Config{fs: fs}
+	c.registerFlags(fs)
+
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	// values given on the command line are never overridden
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	if !explicit["config"] {
+		c.File = os.Getenv(envName("config"))
+	}
+
+	if c.File != "" {
+		err = c.loadFile(c.File, explicit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load config file: %s", err)
+		}
+	}
+
+	err = c.loadEnv(explicit)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// loadFile reads a json object whose keys are flag names
+func (c *Config) loadFile(path string, explicit map[string]bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var values map[string]any
+	err = json.Unmarshal(data, &values)
+	if err != nil {
+		return err
+	}
+
+	for name, value := range values {
+		if name == "config" || name == "print-config" || c.fs.Lookup(name) == nil {
+			return fmt.Errorf("unknown config key: %s", name)
+		}
+
+		if explicit[name] {
+			continue
+		}
+
+		// lists set the flag once per element
+		items, ok := value.([]any)
+		if !ok {
+			items = []any{value}
+		}
+
+		for _, item := range items {
+			var s string
+			switch v := item.(type) {
+			case string:
+				s = v
+			case float64:
+				s = strconv.FormatFloat(v, 'f', -1, 64)
+			case bool:
+				s = strconv.FormatBool(v)
+			default:
+				return fmt.Errorf("invalid value for config key %s", name)
+			}
+
+			err = c.fs.Set(name, s)
+			if err != nil {
+				return fmt.Errorf("invalid value for config key %s: %s", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) loadEnv(explicit map[string]bool) error {
+	var err error
+	c.fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || f.Name == "config" || f.Name == "print-config" {
+			return
+		}
+
+		if explicit[f.Name] {
+			return
+		}
+
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok {
+			return
+		}
+
+		// lists from the environment replace the file's instead of adding to it
+		if l, ok := f.Value.(interface{ reset() }); ok {
+			l.reset()
+		}
+
+		err = f.Value.Set(value)
+		if err != nil {
+			err = fmt.Errorf("invalid value for %s: %s", envName(f.Name), err)
+		}
+	})
+
+	return err
+}
+
+// envName returns the environment variable that overrides a flag
+func envName(flag string) string {
+	return "CLOUDBOX_" + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// Validate checks the config for values that would fail at runtime
+func (c *Config) Validate() error {
+	var errs []error
+
+	if !slices.Contains([]string{"mysql", "sqlite"}, c.DBDriver) {
+		errs = append(errs, fmt.Errorf("dbdriver must be mysql or sqlite, got %q", c.DBDriver))
+	}
+
+	if c.DBDriver == "sqlite" && c.DBPath == "" {
+		errs = append(errs, errors.New("dbpath is required for the sqlite driver"))
+	}
+
+	if !slices.Contains([]string{"tcp", "unix"}, c.DBProto) {
+		errs = append(errs, fmt.Errorf("dbproto must be tcp or unix, got %q", c.DBProto))
+	}
+
+	if !slices.Contains([]string{"s3", "local"}, c.Storage) {
+		errs = append(errs, fmt.Errorf("storage must be s3 or local, got %q", c.Storage))
+	}
+
+	if c.Storage == "local" && c.StorageDir == "" {
+		errs = append(errs, errors.New("storagedir is required for the local storage backend"))
+	}
+
+	if c.ContentBucket == "" || c.ImageBucket == "" {
+		errs = append(errs, errors.New("contentbucket and imagebucket must not be empty"))
+	}
+
+	for name, value := range map[string]string{"contenturl": c.ContentURL, "imageurl": c.ImageURL} {
+		err := validateURL(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", name, err))
+		}
+	}
+
+	for name, value := range map[string]string{"statswebhook": c.StatsWebhook, "savewebhook": c.SaveWebhook} {
+		if value == "" {
+			continue
+		}
+
+		// don't leak the webhook token in the error
+		if validateURL(value) != nil {
+			errs = append(errs, fmt.Errorf("%s is not a valid url", name))
+		}
+	}
+
+	if c.DiscordQueue < 1 {
+		errs = append(errs, errors.New("discordqueue must be at least 1"))
+	}
+
+	if !slices.Contains([]string{"tcp", "unix"}, c.Proto) {
+		errs = append(errs, fmt.Errorf("proto must be tcp or unix, got %q", c.Proto))
+	}
+
+	if c.Addr == "" {
+		errs = append(errs, errors.New("addr must not be empty"))
+	}
+
+	if c.TLSAddr != "" {
+		switch {
+		case c.TLSCert != "" || c.TLSKey != "":
+			if c.TLSCert == "" || c.TLSKey == "" {
+				errs = append(errs, errors.New("tlscert and tlskey must be set together"))
+			}
+		case c.ACMEDir != "":
+			if len(c.ACMEHostList()) == 0 {
+				errs = append(errs, errors.New("acmehosts must name at least one host when using acme"))
+			}
+		default:
+			errs = append(errs, errors.New("tlsaddr requires either tlscert and tlskey or acmedir"))
+		}
+	}
+
+	var level slog.Level
+	if level.UnmarshalText([]byte(c.LogLevel)) != nil {
+		errs = append(errs, fmt.Errorf("loglevel must be debug, info, warn or error, got %q", c.LogLevel))
+	}
+
+	if !slices.Contains([]string{"text", "json"}, c.LogFormat) {
+		errs = append(errs, fmt.Errorf("logformat must be text or json, got %q", c.LogFormat))
+	}
+
+	for name, value := range map[string]time.Duration{"readtimeout": c.ReadTimeout, "readheadertimeout": c.ReadHeaderTimeout, "writetimeout": c.WriteTimeout, "idletimeout": c.IdleTimeout, "shutdowntimeout": c.ShutdownTimeout} {
+		if value < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative", name))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func validateURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported url scheme %q", u.Scheme)
+	}
+
+	if u.Host == "" {
+		return errors.New("missing url host")
+	}
+
+	return nil
+}
+
+// ACMEHostList splits acmehosts, ignoring blank entries and the space around names
+func (c *Config) ACMEHostList() []string {
+	var hosts []string
+	for _, host := range strings.Split(c.ACMEHosts, ",") {
+		host = strings.TrimSpace(host)
+		if host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+
+	return hosts
+}
+
+// Logger returns a logger using the configured level and format
+func (c *Config) Logger() *slog.Logger {
+	var level slog.Level
+	level.UnmarshalText([]byte(c.LogLevel))
+
+	opts := &slog.HandlerOptions{Level: level}
+	if c.LogFormat == "json" {
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts))
+	}
+
+	return slog.New(slog.NewTextHandler(os.Stderr, opts))
+}
+
+// Print writes the effective config as json, in the same format loadFile reads
+func (c *Config) Print(w io.Writer) error {
+	values := make(map[string]any)
+	c.fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" || f.Name == "print-config" {
+			return
+		}
+
+		// sinks are printed as a list with their urls and secrets hidden
+		if f.Name == "sink" {
+			sinks := []string{}
+			for _, s := range c.Sinks {
+				sinks = append(sinks, s.Redacted())
+			}
+
+			values[f.Name] = sinks
+			return
+		}
+
+		value := f.Value.String()
+		if value != "" && slices.Contains(secrets, f.Name) {
+			value = "REDACTED"
+		}
+
+		values[f.Name] = value
+	})
+
+	e := json.NewEncoder(w)
+	e.SetIndent("", "\t")
+
+	return e.Encode(values)
+}
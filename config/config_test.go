@@ -0,0 +1,117 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestACMEHosts(t *testing.T) {
+	for hosts, want := range map[string][]string{
+		"a.com":               {"a.com"},
+		" a.com , b.com,,":    {"a.com", "b.com"},
+		"a.com,\tb.com\n,  ,": {"a.com", "b.com"},
+		" , ,":                nil,
+		"":                    nil,
+	} {
+		c := &Config{ACMEHosts: hosts}
+		if got := c.ACMEHostList(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %q for %q, want %q", got, hosts, want)
+		}
+	}
+}
+
+func TestValidateACMEHosts(t *testing.T) {
+	for hosts, ok := range map[string]bool{"a.com": true, " , ": false, "": false} {
+		c := validConfig(t)
+		c.TLSAddr = ":443"
+		c.ACMEDir = t.TempDir()
+		c.ACMEHosts = hosts
+
+		err := c.Validate()
+		if ok && err != nil {
+			t.Fatalf("rejected %q: %s", hosts, err)
+		}
+
+		if !ok && (err == nil || !strings.Contains(err.Error(), "acmehosts")) {
+			t.Fatalf("got error %v for %q", err, hosts)
+		}
+	}
+}
+
+// validConfig returns the defaults, which pass Validate
+func validConfig(t *testing.T, args ...string) *Config {
+	t.Helper()
+
+	c, err := Parse(flag.NewFlagSet("test", flag.ContinueOnError), args)
+	if err != nil {
+		t.Fatalf("failed to parse config: %s", err)
+	}
+
+	err = c.Validate()
+	if err != nil {
+		t.Fatalf("defaults don't validate: %s", err)
+	}
+
+	return c
+}
+
+func TestSinkPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.json")
+
+	err := os.WriteFile(file, []byte(`{"sink": ["type=file path=file1", "type=file path=file2"], "addr": "127.0.0.1:1"}`), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config file: %s", err)
+	}
+
+	paths := func(c *Config) []string {
+		var paths []string
+		for _, s := range c.Sinks {
+			paths = append(paths, s.Path)
+		}
+
+		return paths
+	}
+
+	c := validConfig(t, "-config", file)
+	if got := paths(c); !reflect.DeepEqual(got, []string{"file1", "file2"}) {
+		t.Fatalf("got sinks %q from the file", got)
+	}
+
+	t.Setenv("CLOUDBOX_SINK", "type=file path=env1\ntype=file path=env2")
+
+	c = validConfig(t, "-config", file)
+	if got := paths(c); !reflect.DeepEqual(got, []string{"env1", "env2"}) {
+		t.Fatalf("got sinks %q with env set, want only the env ones", got)
+	}
+
+	if c.Addr != "127.0.0.1:1" {
+		t.Fatalf("lost addr from the file: %q", c.Addr)
+	}
+
+	c = validConfig(t, "-config", file, "-sink", "type=file path=flag1", "-sink", "type=file path=flag2")
+	if got := paths(c); !reflect.DeepEqual(got, []string{"flag1", "flag2"}) {
+		t.Fatalf("got sinks %q with flags set, want only the flag ones", got)
+	}
+}
@@ -0,0 +1,114 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/flatgrassdotnet/cloudbox/db"
+	"github.com/flatgrassdotnet/cloudbox/gma"
+)
+
+// runContent handles "cloudbox content import [-f] <package id> <path> <file>|dedupe|gc [-n] [-grace duration]"
+func runContent(database *db.DB, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: content import [-f] <package id> <path> <file>|dedupe|gc [-n] [-grace duration]")
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "import":
+		fs := flag.NewFlagSet("import", flag.ContinueOnError)
+		force := fs.Bool("f", false, "import files gmad wouldn't allow in an addon")
+
+		err := fs.Parse(args[1:])
+		if err != nil {
+			return err
+		}
+
+		args = fs.Args()
+		if len(args) != 3 {
+			return errors.New("usage: content import [-f] <package id> <path> <file>")
+		}
+
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid package id: %s", args[0])
+		}
+
+		// they would be left out of the package's gma
+		if !*force && !gma.DefaultWhitelist.Allowed(args[1]) {
+			return fmt.Errorf("%s isn't on the addon whitelist, use -f to import it anyway", args[1])
+		}
+
+		f, err := os.Open(args[2])
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+
+		fid, err := database.ImportContentFile(ctx, id, args[1], f)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("imported %s as file %d\n", args[1], fid)
+	case "dedupe":
+		moved, err := database.DedupeContentFiles(ctx)
+		fmt.Printf("moved %d files into blobs\n", moved)
+		if err != nil {
+			return err
+		}
+	case "gc":
+		fs := flag.NewFlagSet("gc", flag.ContinueOnError)
+		dryRun := fs.Bool("n", false, "only list the blobs that would be deleted")
+		grace := fs.Duration("grace", 24*time.Hour, "keep blobs younger than this")
+
+		err := fs.Parse(args[1:])
+		if err != nil {
+			return err
+		}
+
+		keys, err := database.CollectBlobs(ctx, *grace, *dryRun)
+		for _, key := range keys {
+			fmt.Println(key)
+		}
+		if err != nil {
+			return err
+		}
+
+		if *dryRun {
+			fmt.Printf("%d unreferenced blobs\n", len(keys))
+		} else {
+			fmt.Printf("deleted %d blobs\n", len(keys))
+		}
+	default:
+		return fmt.Errorf("unknown content command: %s", args[0])
+	}
+
+	return nil
+}
@@ -0,0 +1,378 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flatgrassdotnet/cloudbox/metrics"
+	"github.com/flatgrassdotnet/cloudbox/storage"
+)
+
+// content is stored once per distinct file, keyed by its sha256
+// files rows are the handles packages use, any number of them can point at the same blob
+
+const blobPrefix = "blobs/"
+
+func blobKey(sum string) string {
+	return blobPrefix + sum
+}
+
+type blob struct {
+	SHA256 string
+	CRC    uint32
+	Size   int64
+}
+
+// putBlob stores the contents of r unless an identical blob is already there
+// the blob is claimed so gc leaves it alone, call release once the rows referring to it are committed
+func (d *DB) putBlob(ctx context.Context, r io.Reader) (b blob, release func(), err error) {
+	// the key is only known once everything is read
+	f, err := os.CreateTemp("", "cloudbox-blob-*")
+	if err != nil {
+		return blob{}, nil, err
+	}
+
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	c := crc32.NewIEEE()
+	s := sha256.New()
+
+	size, err := io.Copy(io.MultiWriter(f, c, s), r)
+	if err != nil {
+		return blob{}, nil, err
+	}
+
+	b = blob{SHA256: hex.EncodeToString(s.Sum(nil)), CRC: c.Sum32(), Size: size}
+
+	release, err = d.claimBlob(ctx, b.SHA256, false)
+	if err != nil {
+		return blob{}, nil, err
+	}
+
+	err = d.storeBlob(ctx, b.SHA256, f)
+	if err != nil {
+		release()
+		return blob{}, nil, err
+	}
+
+	return b, release, nil
+}
+
+// storeBlob uploads body as the blob sum if it isn't there or gc may have just deleted it
+func (d *DB) storeBlob(ctx context.Context, sum string, body io.ReadSeeker) error {
+	collected, err := d.waitForCollect(ctx, sum)
+	if err != nil {
+		return err
+	}
+
+	if !collected {
+		_, err = d.content.Stat(ctx, blobKey(sum))
+		if err == nil {
+			return nil
+		}
+
+		if !errors.Is(err, storage.ErrNotExist) {
+			return err
+		}
+	}
+
+	_, err = body.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	return d.content.Put(ctx, blobKey(sum), body, storage.PutOptions{ContentType: "application/octet-stream"})
+}
+
+// gc claims older than this are left over from a gc that died, imports stop waiting for them
+const collectTimeout = 10 * time.Minute
+
+// gc drops claims older than this before it starts
+const staleClaimAge = 24 * time.Hour
+
+// how often an import checks whether gc is done with a blob
+const collectPoll = 100 * time.Millisecond
+
+// claimBlob tells imports (gc false) or gc (gc true) that sum is in use until release is called
+func (d *DB) claimBlob(ctx context.Context, sum string, gc bool) (release func(), err error) {
+	r, err := d.handle.ExecContext(ctx, "INSERT INTO blob_claims (sha256, gc, created) VALUES (?, ?, ?)", sum, gc, time.Now().UTC().Format(time.DateTime))
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := r.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		// the caller's context may be done already, the claim has to go either way
+		_, err := d.handle.Exec("DELETE FROM blob_claims WHERE id = ?", id)
+		if err != nil {
+			log.Printf("failed to release blob claim %d: %s", id, err)
+		}
+	}, nil
+}
+
+// waitForCollect waits until no running gc claims sum and reports whether one did
+// the blob may be gone afterwards even if it was there before
+func (d *DB) waitForCollect(ctx context.Context, sum string) (bool, error) {
+	var collected bool
+	for {
+		var claims int
+		err := d.handle.QueryRowContext(ctx, "SELECT COUNT(*) FROM blob_claims WHERE sha256 = ? AND gc = ? AND created > ?", sum, true, time.Now().UTC().Add(-collectTimeout).Format(time.DateTime)).Scan(&claims)
+		if err != nil {
+			return collected, err
+		}
+
+		if claims == 0 {
+			return collected, nil
+		}
+
+		collected = true
+
+		select {
+		case <-ctx.Done():
+			return collected, ctx.Err()
+		case <-time.After(collectPoll):
+		}
+	}
+}
+
+// ImportContentFile adds a file to the content of package id and returns the new file id
+func (d *DB) ImportContentFile(ctx context.Context, id int, path string, body io.Reader) (int, error) {
+	defer metrics.ObserveQuery("ImportContentFile", time.Now())
+
+	b, release, err := d.putBlob(ctx, body)
+	if err != nil {
+		return 0, err
+	}
+
+	defer release()
+
+	// both rows or neither, a files row outside any package would only be cleaned up by gc
+	tx, err := d.handle.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	// stored uncompressed, so psize is the raw size
+	r, err := tx.ExecContext(ctx, "INSERT INTO files (path, size, psize, crc, sha256, blob_sha256) VALUES (?, ?, ?, ?, ?, ?)", path, b.Size, b.Size, b.CRC, b.SHA256, b.SHA256)
+	if err != nil {
+		return 0, err
+	}
+
+	fid, err := r.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO content (id, fileid) VALUES (?, ?)", id, fid)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(fid), nil
+}
+
+// DedupeContentFiles moves files still stored under their id into blobs and returns how many were moved
+// files whose object is missing are left alone
+func (d *DB) DedupeContentFiles(ctx context.Context) (int, error) {
+	defer metrics.ObserveQuery("DedupeContentFiles", time.Now())
+
+	rows, err := d.handle.Query("SELECT id FROM files WHERE blob_sha256 IS NULL")
+	if err != nil {
+		return 0, err
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	rows.Close()
+
+	var moved int
+	for _, id := range ids {
+		o, err := d.content.Get(ctx, strconv.Itoa(id))
+		if err != nil {
+			if errors.Is(err, storage.ErrNotExist) {
+				continue
+			}
+
+			return moved, err
+		}
+
+		b, release, err := d.putBlob(ctx, o.Body)
+		o.Body.Close()
+		if err != nil {
+			return moved, err
+		}
+
+		_, err = d.handle.Exec("UPDATE files SET crc = ?, sha256 = ?, blob_sha256 = ? WHERE id = ?", b.CRC, b.SHA256, b.SHA256, id)
+		release()
+		if err != nil {
+			return moved, err
+		}
+
+		err = d.content.Delete(ctx, strconv.Itoa(id))
+		if err != nil && !errors.Is(err, storage.ErrNotExist) {
+			return moved, err
+		}
+
+		moved++
+	}
+
+	return moved, nil
+}
+
+// CollectBlobs deletes blobs no package content refers to and returns their keys
+// blobs younger than grace are kept, and so are blobs an import has claimed
+// files rows outside any package that point at a deleted blob are deleted with it
+// with dryRun nothing is deleted
+func (d *DB) CollectBlobs(ctx context.Context, grace time.Duration, dryRun bool) ([]string, error) {
+	defer metrics.ObserveQuery("CollectBlobs", time.Now())
+
+	// files imported while we run are newer than this
+	var last int
+	err := d.handle.QueryRow("SELECT COALESCE(MAX(id), 0) FROM files").Scan(&last)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := d.handle.Query("SELECT DISTINCT f.blob_sha256 FROM files f JOIN content c ON c.fileid = f.id WHERE f.blob_sha256 IS NOT NULL")
+	if err != nil {
+		return nil, err
+	}
+
+	used := make(map[string]bool)
+	for rows.Next() {
+		var sum string
+		err = rows.Scan(&sum)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		used[sum] = true
+	}
+
+	rows.Close()
+
+	var garbage []string
+	err = d.content.List(ctx, blobPrefix, func(o storage.ObjectInfo) error {
+		if time.Since(o.ModTime) < grace || used[strings.TrimPrefix(o.Key, blobPrefix)] {
+			return nil
+		}
+
+		garbage = append(garbage, o.Key)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		return garbage, nil
+	}
+
+	// claims left behind by imports and gcs that died, none of them take this long
+	_, err = d.handle.Exec("DELETE FROM blob_claims WHERE created < ?", time.Now().UTC().Add(-staleClaimAge).Format(time.DateTime))
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []string
+	for _, key := range garbage {
+		ok, err := d.collectBlob(ctx, key, last)
+		if err != nil {
+			return deleted, err
+		}
+
+		if ok {
+			deleted = append(deleted, key)
+		}
+	}
+
+	return deleted, nil
+}
+
+// collectBlob deletes the blob at key unless it's referred to or claimed by an import
+// files newer than last were imported after gc started and always count
+func (d *DB) collectBlob(ctx context.Context, key string, last int) (bool, error) {
+	sum := strings.TrimPrefix(key, blobPrefix)
+
+	// imports claim before checking for the blob, so either they see this claim and upload it again
+	// or their claim is already there when we check below
+	release, err := d.claimBlob(ctx, sum, true)
+	if err != nil {
+		return false, err
+	}
+
+	defer release()
+
+	var refs int
+	err = d.handle.QueryRowContext(ctx, "SELECT (SELECT COUNT(*) FROM files WHERE blob_sha256 = ? AND (id > ? OR id IN (SELECT fileid FROM content))) + (SELECT COUNT(*) FROM blob_claims WHERE sha256 = ? AND gc = ?)", sum, last, sum, false).Scan(&refs)
+	if err != nil {
+		return false, err
+	}
+
+	if refs != 0 {
+		return false, nil
+	}
+
+	_, err = d.handle.ExecContext(ctx, "DELETE FROM files WHERE blob_sha256 = ?", sum)
+	if err != nil {
+		return false, err
+	}
+
+	err = d.content.Delete(ctx, key)
+	if err != nil && !errors.Is(err, storage.ErrNotExist) {
+		return false, err
+	}
+
+	return true, nil
+}
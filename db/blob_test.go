@@ -0,0 +1,228 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newBlobTestDB opens a migrated sqlite database with local storage under a temp dir
+func newBlobTestDB(t *testing.T) (*DB, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	d, err := OpenSQLite(filepath.Join(dir, "cloudbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { d.Close() })
+
+	_, err = d.MigrateUp()
+	if err != nil {
+		t.Fatalf("failed to migrate: %s", err)
+	}
+
+	err = d.InitStorage("local", dir, "content", "images")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return d, filepath.Join(dir, "content")
+}
+
+// putOldBlob stores body as an unreferenced blob that's well past any grace period
+func putOldBlob(t *testing.T, d *DB, dir string, body string) string {
+	t.Helper()
+
+	b, release, err := d.putBlob(context.Background(), strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release()
+
+	old := time.Now().Add(-48 * time.Hour)
+	err = os.Chtimes(filepath.Join(dir, blobKey(b.SHA256)), old, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b.SHA256
+}
+
+func blobExists(t *testing.T, d *DB, sum string) bool {
+	t.Helper()
+
+	_, err := d.content.Stat(context.Background(), blobKey(sum))
+
+	return err == nil
+}
+
+func TestCollectBlobs(t *testing.T) {
+	d, dir := newBlobTestDB(t)
+	ctx := context.Background()
+
+	garbage := putOldBlob(t, d, dir, "nobody wants this")
+	kept := putOldBlob(t, d, dir, "package content")
+
+	_, err := d.ImportContentFile(ctx, 1, "materials/kept.vmt", strings.NewReader("package content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := d.CollectBlobs(ctx, time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deleted) != 1 || deleted[0] != blobKey(garbage) {
+		t.Errorf("deleted %v, want only %s", deleted, blobKey(garbage))
+	}
+
+	if blobExists(t, d, garbage) {
+		t.Error("unreferenced blob still exists")
+	}
+
+	if !blobExists(t, d, kept) {
+		t.Error("referenced blob was deleted")
+	}
+}
+
+// an import that found the blob already there but hasn't committed its rows yet
+func TestCollectBlobsDuringImport(t *testing.T) {
+	d, dir := newBlobTestDB(t)
+	ctx := context.Background()
+
+	sum := putOldBlob(t, d, dir, "imported twice")
+
+	b, release, err := d.putBlob(ctx, strings.NewReader("imported twice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := d.CollectBlobs(ctx, time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deleted) != 0 {
+		t.Errorf("deleted %v while an import held the blob", deleted)
+	}
+
+	r, err := d.handle.Exec("INSERT INTO files (path, size, psize, crc, sha256, blob_sha256) VALUES (?, ?, ?, ?, ?, ?)", "a.txt", b.Size, b.Size, b.CRC, b.SHA256, b.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fid, _ := r.LastInsertId()
+	_, err = d.handle.Exec("INSERT INTO content (id, fileid) VALUES (?, ?)", 1, fid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release()
+
+	deleted, err = d.CollectBlobs(ctx, time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deleted) != 0 || !blobExists(t, d, sum) {
+		t.Errorf("deleted %v after the import committed", deleted)
+	}
+}
+
+// an import that starts while gc is deleting the blob has to upload it again
+func TestImportDuringCollect(t *testing.T) {
+	d, dir := newBlobTestDB(t)
+	ctx := context.Background()
+
+	sum := putOldBlob(t, d, dir, "collected and imported")
+
+	collect, err := d.claimBlob(ctx, sum, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, release, err := d.putBlob(ctx, strings.NewReader("collected and imported"))
+		if err == nil {
+			release()
+		}
+
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("import went ahead while gc held the blob: %v", err)
+	case <-time.After(3 * collectPoll):
+	}
+
+	err = d.content.Delete(ctx, blobKey(sum))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collect()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("import still waiting after gc released the blob")
+	}
+
+	if !blobExists(t, d, sum) {
+		t.Error("import didn't upload the blob gc deleted")
+	}
+}
+
+// a claim left by a gc that died doesn't hold imports up
+func TestImportIgnoresStaleCollect(t *testing.T) {
+	d, dir := newBlobTestDB(t)
+	ctx := context.Background()
+
+	sum := putOldBlob(t, d, dir, "stale")
+
+	_, err := d.handle.Exec("INSERT INTO blob_claims (sha256, gc, created) VALUES (?, ?, ?)", sum, true, time.Now().UTC().Add(-2*collectTimeout).Format(time.DateTime))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	_, release, err := d.putBlob(ctx, strings.NewReader("stale"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release()
+}
@@ -0,0 +1,59 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"strings"
+)
+
+// dialect holds the bits of sql that differ between database servers
+type dialect struct {
+	name string
+
+	random      string // ORDER BY expression for a random order
+	weekAgo     string // utc timestamp one week ago
+	releaseDate string // timestamp used for scraped packages, gm13's release
+
+	// insertPackageID is the id expression for new packages
+	// empty when the database assigns it with AUTO_INCREMENT
+	insertPackageID string
+
+	concat func(args ...string) string
+}
+
+var mysql = dialect{
+	name:        "mysql",
+	random:      "RAND()",
+	weekAgo:     "DATE_SUB(UTC_TIMESTAMP(), INTERVAL 1 WEEK)",
+	releaseDate: "STR_TO_DATE('2012-10-25', '%Y-%c-%d')",
+	concat: func(args ...string) string {
+		return "CONCAT(" + strings.Join(args, ", ") + ")"
+	},
+}
+
+var sqlite = dialect{
+	name:            "sqlite",
+	random:          "RANDOM()",
+	weekAgo:         "DATETIME('now', '-7 days')",
+	releaseDate:     "'2012-10-25 00:00:00'",
+	insertPackageID: "(SELECT COALESCE(MAX(id), 0) + 1 FROM packages)",
+	concat: func(args ...string) string {
+		return "(" + strings.Join(args, " || ") + ")"
+	},
+}
@@ -0,0 +1,84 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/flatgrassdotnet/cloudbox/common"
+	"github.com/flatgrassdotnet/cloudbox/metrics"
+	"github.com/flatgrassdotnet/cloudbox/storage"
+)
+
+// FetchGMAArtifact returns the gma last built for a package revision and variant, sql.ErrNoRows if there is none
+func (d *DB) FetchGMAArtifact(id int, rev int, variant string) (common.GMAArtifact, error) {
+	defer metrics.ObserveQuery("FetchGMAArtifact", time.Now())
+
+	a := common.GMAArtifact{ID: id, Revision: rev, Variant: variant}
+	err := d.handle.QueryRow("SELECT fingerprint, objectkey, size, sha256, created FROM gmas WHERE id = ? AND rev = ? AND variant = ?", id, rev, variant).Scan(&a.Fingerprint, &a.Key, &a.Size, &a.SHA256, timeValue{&a.Created})
+	if err != nil {
+		return common.GMAArtifact{}, err
+	}
+
+	return a, nil
+}
+
+// StoreGMAArtifact uploads a built gma and records it, replacing the previous build of the same revision and variant
+// a.Key is assigned here, body must hold exactly a.Size bytes
+func (d *DB) StoreGMAArtifact(a common.GMAArtifact, body io.Reader) (common.GMAArtifact, error) {
+	defer metrics.ObserveQuery("StoreGMAArtifact", time.Now())
+
+	a.Key = fmt.Sprintf("gma/%d_%d_%s.gma", a.ID, a.Revision, a.Fingerprint)
+
+	err := d.content.Put(context.TODO(), a.Key, body, storage.PutOptions{ContentType: "application/octet-stream"})
+	if err != nil {
+		return common.GMAArtifact{}, err
+	}
+
+	var old string
+	err = d.handle.QueryRow("SELECT objectkey FROM gmas WHERE id = ? AND rev = ? AND variant = ?", a.ID, a.Revision, a.Variant).Scan(&old)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return common.GMAArtifact{}, err
+	}
+
+	_, err = d.handle.Exec("REPLACE INTO gmas (id, rev, variant, fingerprint, objectkey, size, sha256, created) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", a.ID, a.Revision, a.Variant, a.Fingerprint, a.Key, a.Size, a.SHA256, a.Created.UTC().Format(time.DateTime))
+	if err != nil {
+		return common.GMAArtifact{}, err
+	}
+
+	// the old build is unreachable now
+	if old != "" && old != a.Key {
+		err = d.content.Delete(context.TODO(), old)
+		if err != nil && !errors.Is(err, storage.ErrNotExist) {
+			return common.GMAArtifact{}, err
+		}
+	}
+
+	return a, nil
+}
+
+// OpenGMAArtifact returns a reader for a stored gma, it fetches lazily and supports seeking
+func (d *DB) OpenGMAArtifact(ctx context.Context, a common.GMAArtifact) io.ReadSeekCloser {
+	return storage.NewReadSeeker(ctx, d.content, a.Key, a.Size)
+}
@@ -23,30 +23,44 @@ import (
 	"database/sql"
 	"fmt"
 
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/flatgrassdotnet/cloudbox/storage"
 	_ "github.com/go-sql-driver/mysql"
+	_ "modernc.org/sqlite"
 )
 
-var (
-	handle   *sql.DB
-	s3client *s3.Client
-)
+type DB struct {
+	handle *sql.DB
+	dia    dialect
+
+	content storage.ContentStore
+	images  storage.ContentStore
+}
 
-func Init(username string, password string, protocol string, address string, database string) error {
-	db, err := sql.Open("mysql", fmt.Sprintf("%s:%s@%s(%s)/%s?parseTime=true", username, password, protocol, address, database))
+// Open connects to a mysql database
+func Open(username string, password string, protocol string, address string, database string) (*DB, error) {
+	handle, err := sql.Open("mysql", fmt.Sprintf("%s:%s@%s(%s)/%s?parseTime=true", username, password, protocol, address, database))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	handle = db
+	return &DB{handle: handle, dia: mysql}, nil
+}
 
-	cfg, err := config.LoadDefaultConfig(context.TODO())
+// OpenSQLite opens a sqlite database file, creating it if it doesn't exist
+func OpenSQLite(path string) (*DB, error) {
+	handle, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	s3client = s3.NewFromConfig(cfg)
+	return &DB{handle: handle, dia: sqlite}, nil
+}
+
+// PingContext checks that the database is reachable
+func (d *DB) PingContext(ctx context.Context) error {
+	return d.handle.PingContext(ctx)
+}
 
-	return nil
+func (d *DB) Close() error {
+	return d.handle.Close()
 }
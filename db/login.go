@@ -18,8 +18,16 @@
 
 package db
 
-func InsertLogin(steamid string, vac string, ticket []byte) error {
-	_, err := handle.Exec("REPLACE INTO logins (steamid, vac, ticket) VALUES (?, ?, ?)", steamid, vac, ticket)
+import (
+	"time"
+
+	"github.com/flatgrassdotnet/cloudbox/metrics"
+)
+
+func (d *DB) InsertLogin(steamid string, vac string, ticket []byte) error {
+	defer metrics.ObserveQuery("InsertLogin", time.Now())
+
+	_, err := d.handle.Exec("REPLACE INTO logins (steamid, vac, ticket) VALUES (?, ?, ?)", steamid, vac, ticket)
 	if err != nil {
 		return err
 	}
@@ -27,9 +35,11 @@ func InsertLogin(steamid string, vac string, ticket []byte) error {
 	return nil
 }
 
-func FetchSteamIDFromTicket(ticket []byte) (string, error) {
+func (d *DB) FetchSteamIDFromTicket(ticket []byte) (string, error) {
+	defer metrics.ObserveQuery("FetchSteamIDFromTicket", time.Now())
+
 	var steamid string
-	err := handle.QueryRow("SELECT steamid FROM logins WHERE ticket = ?", ticket).Scan(&steamid)
+	err := d.handle.QueryRow("SELECT steamid FROM logins WHERE ticket = ?", ticket).Scan(&steamid)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,293 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations
+var migrationFS embed.FS
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Migration
+	Applied time.Time // zero if not applied
+}
+
+// loadMigrations reads the embedded migrations ordered by version
+// files are named <version>_<name>.up.sql and <version>_<name>.down.sql
+func (d *DB) loadMigrations() ([]Migration, error) {
+	dir := path.Join("migrations", d.dia.name)
+
+	entries, err := fs.ReadDir(migrationFS, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+
+		var up bool
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			up = true
+		case strings.HasSuffix(name, ".down.sql"):
+			up = false
+		default:
+			continue
+		}
+
+		prefix, rest, ok := strings.Cut(name, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name: %s", name)
+		}
+
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %s", name, err)
+		}
+
+		data, err := fs.ReadFile(migrationFS, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version}
+			byVersion[version] = m
+		}
+
+		if up {
+			m.Name = strings.TrimSuffix(rest, ".up.sql")
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	var migrations []Migration
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d is missing its up or down file", m.Version)
+		}
+
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+func (d *DB) ensureMigrationTable() error {
+	_, err := d.handle.Exec("CREATE TABLE IF NOT EXISTS schema_migrations (version INT NOT NULL PRIMARY KEY, name VARCHAR(255) NOT NULL, applied DATETIME NOT NULL)")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (d *DB) fetchAppliedMigrations() (map[int]time.Time, error) {
+	err := d.ensureMigrationTable()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := d.handle.Query("SELECT version, applied FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var t time.Time
+		err := rows.Scan(&version, timeValue{&t})
+		if err != nil {
+			return nil, err
+		}
+
+		applied[version] = t
+	}
+
+	return applied, rows.Err()
+}
+
+// SchemaVersion returns the highest applied migration version, 0 if none are
+func (d *DB) SchemaVersion() (int, error) {
+	err := d.ensureMigrationTable()
+	if err != nil {
+		return 0, err
+	}
+
+	var version sql.NullInt64
+	err = d.handle.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(version.Int64), nil
+}
+
+func (d *DB) FetchMigrationStatus() ([]MigrationStatus, error) {
+	migrations, err := d.loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := d.fetchAppliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var status []MigrationStatus
+	for _, m := range migrations {
+		status = append(status, MigrationStatus{Migration: m, Applied: applied[m.Version]})
+	}
+
+	return status, nil
+}
+
+// MigrateUp applies every pending migration in order and returns the ones it applied
+func (d *DB) MigrateUp() ([]Migration, error) {
+	status, err := d.FetchMigrationStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, s := range status {
+		if !s.Applied.IsZero() {
+			continue
+		}
+
+		err = d.runMigration(s.Version, s.Up, func(tx *sql.Tx) error {
+			_, err := tx.Exec("INSERT INTO schema_migrations (version, name, applied) VALUES (?, ?, ?)", s.Version, s.Name, time.Now().UTC().Format(time.DateTime))
+			return err
+		})
+		if err != nil {
+			return done, err
+		}
+
+		done = append(done, s.Migration)
+	}
+
+	return done, nil
+}
+
+// MigrateDown reverts the latest n applied migrations and returns the ones it reverted
+func (d *DB) MigrateDown(n int) ([]Migration, error) {
+	status, err := d.FetchMigrationStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(status) - 1; i >= 0 && len(done) < n; i-- {
+		s := status[i]
+		if s.Applied.IsZero() {
+			continue
+		}
+
+		err = d.runMigration(s.Version, s.Down, func(tx *sql.Tx) error {
+			_, err := tx.Exec("DELETE FROM schema_migrations WHERE version = ?", s.Version)
+			return err
+		})
+		if err != nil {
+			return done, err
+		}
+
+		done = append(done, s.Migration)
+	}
+
+	return done, nil
+}
+
+// runMigration executes each statement of a migration and records it with record
+// note that mysql commits DDL statements implicitly, so a failed migration may be partially applied
+func (d *DB) runMigration(version int, script string, record func(tx *sql.Tx) error) error {
+	tx, err := d.handle.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	for _, stmt := range splitStatements(script) {
+		_, err = tx.Exec(stmt)
+		if err != nil {
+			return fmt.Errorf("migration %d failed: %s", version, err)
+		}
+	}
+
+	err = record(tx)
+	if err != nil {
+		return fmt.Errorf("failed to record migration %d: %s", version, err)
+	}
+
+	return tx.Commit()
+}
+
+// splitStatements splits a migration script on semicolons at the end of a line
+// lines starting with "--" are comments
+func splitStatements(script string) []string {
+	var stmts []string
+	var current strings.Builder
+	for line := range strings.Lines(script) {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+
+	if s := strings.TrimSpace(current.String()); s != "" {
+		stmts = append(stmts, s)
+	}
+
+	return stmts
+}
@@ -0,0 +1,11 @@
+DROP TABLE news;
+DROP TABLE errors;
+DROP TABLE maploads;
+DROP TABLE uploads;
+DROP TABLE logins;
+DROP TABLE profiles;
+DROP TABLE scraped;
+DROP TABLE content;
+DROP TABLE files;
+DROP TABLE includes;
+DROP TABLE packages;
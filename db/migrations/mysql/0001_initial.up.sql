@@ -0,0 +1,114 @@
+CREATE TABLE packages (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	rev INT UNSIGNED NOT NULL DEFAULT 1,
+	type VARCHAR(32) NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	dataname VARCHAR(255) NULL,
+	author VARCHAR(20) NULL,
+	description TEXT NULL,
+	data LONGBLOB NULL,
+	incompatible BOOLEAN NOT NULL DEFAULT FALSE,
+	time DATETIME NOT NULL DEFAULT (UTC_TIMESTAMP()),
+	PRIMARY KEY (id, rev),
+	KEY packages_type (type),
+	KEY packages_author (author),
+	KEY packages_dataname (dataname)
+);
+
+CREATE TABLE includes (
+	id INT UNSIGNED NOT NULL,
+	rev INT UNSIGNED NOT NULL,
+	includeid INT UNSIGNED NOT NULL,
+	includerev INT UNSIGNED NOT NULL,
+	PRIMARY KEY (id, rev, includeid, includerev)
+);
+
+CREATE TABLE files (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	path VARCHAR(260) NOT NULL,
+	size INT UNSIGNED NOT NULL,
+	psize INT UNSIGNED NOT NULL,
+	PRIMARY KEY (id),
+	KEY files_path (path)
+);
+
+CREATE TABLE content (
+	id INT UNSIGNED NOT NULL,
+	fileid INT UNSIGNED NOT NULL,
+	PRIMARY KEY (id, fileid),
+	KEY content_fileid (fileid)
+);
+
+CREATE TABLE scraped (
+	id INT UNSIGNED NOT NULL,
+	rev INT UNSIGNED NOT NULL,
+	type VARCHAR(32) NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	author VARCHAR(255) NULL,
+	description TEXT NULL,
+	downloads INT UNSIGNED NOT NULL DEFAULT 0,
+	favorites INT UNSIGNED NOT NULL DEFAULT 0,
+	goods INT UNSIGNED NOT NULL DEFAULT 0,
+	bads INT UNSIGNED NOT NULL DEFAULT 0,
+	PRIMARY KEY (id, rev)
+);
+
+CREATE TABLE profiles (
+	steamid VARCHAR(20) NOT NULL,
+	personaname VARCHAR(255) NOT NULL,
+	avatar VARCHAR(255) NOT NULL,
+	avatarmedium VARCHAR(255) NOT NULL,
+	avatarfull VARCHAR(255) NOT NULL,
+	time DATETIME NOT NULL DEFAULT (UTC_TIMESTAMP()),
+	PRIMARY KEY (steamid)
+);
+
+CREATE TABLE logins (
+	steamid VARCHAR(20) NOT NULL,
+	vac VARCHAR(255) NOT NULL,
+	ticket VARBINARY(24) NOT NULL,
+	time DATETIME NOT NULL DEFAULT (UTC_TIMESTAMP()),
+	PRIMARY KEY (steamid),
+	UNIQUE KEY logins_ticket (ticket)
+);
+
+CREATE TABLE uploads (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	steamid BIGINT UNSIGNED NOT NULL,
+	type VARCHAR(32) NOT NULL,
+	meta TEXT NOT NULL,
+	includes TEXT NOT NULL,
+	data LONGBLOB NOT NULL,
+	time DATETIME NOT NULL DEFAULT (UTC_TIMESTAMP()),
+	PRIMARY KEY (id)
+);
+
+CREATE TABLE maploads (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	steamid VARCHAR(20) NOT NULL,
+	duration FLOAT NOT NULL,
+	map VARCHAR(255) NOT NULL,
+	platform VARCHAR(8) NOT NULL,
+	time DATETIME NOT NULL DEFAULT (UTC_TIMESTAMP()),
+	PRIMARY KEY (id)
+);
+
+CREATE TABLE errors (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	steamid VARCHAR(20) NOT NULL,
+	error TEXT NOT NULL,
+	content TEXT NOT NULL,
+	realm VARCHAR(8) NOT NULL,
+	platform VARCHAR(8) NOT NULL,
+	time DATETIME NOT NULL DEFAULT (UTC_TIMESTAMP()),
+	PRIMARY KEY (id)
+);
+
+CREATE TABLE news (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	title VARCHAR(255) NOT NULL,
+	body TEXT NOT NULL,
+	author VARCHAR(255) NOT NULL,
+	time DATETIME NOT NULL DEFAULT (UTC_TIMESTAMP()),
+	PRIMARY KEY (id)
+);
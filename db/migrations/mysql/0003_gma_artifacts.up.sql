@@ -0,0 +1,11 @@
+-- built gmas kept in the content store, one per package revision
+CREATE TABLE gmas (
+	id INT UNSIGNED NOT NULL,
+	rev INT UNSIGNED NOT NULL,
+	fingerprint CHAR(64) NOT NULL,
+	objectkey VARCHAR(255) NOT NULL,
+	size BIGINT UNSIGNED NOT NULL,
+	sha256 CHAR(64) NOT NULL,
+	created DATETIME NOT NULL,
+	PRIMARY KEY (id, rev)
+);
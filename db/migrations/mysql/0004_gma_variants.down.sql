@@ -0,0 +1,3 @@
+DELETE FROM gmas WHERE variant != '';
+ALTER TABLE gmas DROP PRIMARY KEY, ADD PRIMARY KEY (id, rev);
+ALTER TABLE gmas DROP COLUMN variant;
@@ -0,0 +1,3 @@
+-- builds with includes merged in are kept next to the plain build of the same revision
+ALTER TABLE gmas ADD COLUMN variant VARCHAR(16) NOT NULL DEFAULT '' AFTER rev;
+ALTER TABLE gmas DROP PRIMARY KEY, ADD PRIMARY KEY (id, rev, variant);
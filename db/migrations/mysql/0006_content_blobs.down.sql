@@ -0,0 +1,3 @@
+-- files already moved into blobs can't be read after this
+DROP INDEX files_blob_sha256 ON files;
+ALTER TABLE files DROP COLUMN blob_sha256;
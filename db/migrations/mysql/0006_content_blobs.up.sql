@@ -0,0 +1,3 @@
+-- sha256 of the blob holding the file, NULL while it's still stored under its id
+ALTER TABLE files ADD COLUMN blob_sha256 CHAR(64) NULL;
+CREATE INDEX files_blob_sha256 ON files (blob_sha256);
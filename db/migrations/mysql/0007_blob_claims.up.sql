@@ -0,0 +1,11 @@
+-- blobs an import is about to refer to (gc = 0) or gc is about to delete (gc = 1)
+-- both write their claim before looking for the other's, so they can't both go ahead
+CREATE TABLE blob_claims (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	sha256 CHAR(64) NOT NULL,
+	gc BOOLEAN NOT NULL,
+	created DATETIME NOT NULL,
+	PRIMARY KEY (id)
+);
+
+CREATE INDEX blob_claims_sha256 ON blob_claims (sha256);
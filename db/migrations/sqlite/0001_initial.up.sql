@@ -0,0 +1,111 @@
+-- sqlite only allows AUTOINCREMENT on single column primary keys
+-- new package ids are assigned by the insert query instead
+CREATE TABLE packages (
+	id INTEGER NOT NULL,
+	rev INTEGER NOT NULL DEFAULT 1,
+	type TEXT NOT NULL,
+	name TEXT NOT NULL,
+	dataname TEXT NULL,
+	author TEXT NULL,
+	description TEXT NULL,
+	data BLOB NULL,
+	incompatible BOOLEAN NOT NULL DEFAULT FALSE,
+	time DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (id, rev)
+);
+
+CREATE INDEX packages_type ON packages (type);
+CREATE INDEX packages_author ON packages (author);
+CREATE INDEX packages_dataname ON packages (dataname);
+
+CREATE TABLE includes (
+	id INTEGER NOT NULL,
+	rev INTEGER NOT NULL,
+	includeid INTEGER NOT NULL,
+	includerev INTEGER NOT NULL,
+	PRIMARY KEY (id, rev, includeid, includerev)
+);
+
+CREATE TABLE files (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	path TEXT NOT NULL,
+	size INTEGER NOT NULL,
+	psize INTEGER NOT NULL
+);
+
+CREATE INDEX files_path ON files (path);
+
+CREATE TABLE content (
+	id INTEGER NOT NULL,
+	fileid INTEGER NOT NULL,
+	PRIMARY KEY (id, fileid)
+);
+
+CREATE INDEX content_fileid ON content (fileid);
+
+CREATE TABLE scraped (
+	id INTEGER NOT NULL,
+	rev INTEGER NOT NULL,
+	type TEXT NOT NULL,
+	name TEXT NOT NULL,
+	author TEXT NULL,
+	description TEXT NULL,
+	downloads INTEGER NOT NULL DEFAULT 0,
+	favorites INTEGER NOT NULL DEFAULT 0,
+	goods INTEGER NOT NULL DEFAULT 0,
+	bads INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (id, rev)
+);
+
+CREATE TABLE profiles (
+	steamid TEXT NOT NULL PRIMARY KEY,
+	personaname TEXT NOT NULL,
+	avatar TEXT NOT NULL,
+	avatarmedium TEXT NOT NULL,
+	avatarfull TEXT NOT NULL,
+	time DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE logins (
+	steamid TEXT NOT NULL PRIMARY KEY,
+	vac TEXT NOT NULL,
+	ticket BLOB NOT NULL UNIQUE,
+	time DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE uploads (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	steamid INTEGER NOT NULL,
+	type TEXT NOT NULL,
+	meta TEXT NOT NULL,
+	includes TEXT NOT NULL,
+	data BLOB NOT NULL,
+	time DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE maploads (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	steamid TEXT NOT NULL,
+	duration REAL NOT NULL,
+	map TEXT NOT NULL,
+	platform TEXT NOT NULL,
+	time DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE errors (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	steamid TEXT NOT NULL,
+	error TEXT NOT NULL,
+	content TEXT NOT NULL,
+	realm TEXT NOT NULL,
+	platform TEXT NOT NULL,
+	time DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE news (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	title TEXT NOT NULL,
+	body TEXT NOT NULL,
+	author TEXT NOT NULL,
+	time DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
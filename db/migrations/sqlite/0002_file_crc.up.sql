@@ -0,0 +1,2 @@
+-- crc32 of the raw file, filled in the first time a gma containing the file is built
+ALTER TABLE files ADD COLUMN crc INTEGER;
@@ -0,0 +1,11 @@
+-- built gmas kept in the content store, one per package revision
+CREATE TABLE gmas (
+	id INTEGER NOT NULL,
+	rev INTEGER NOT NULL,
+	fingerprint TEXT NOT NULL,
+	objectkey TEXT NOT NULL,
+	size INTEGER NOT NULL,
+	sha256 TEXT NOT NULL,
+	created DATETIME NOT NULL,
+	PRIMARY KEY (id, rev)
+);
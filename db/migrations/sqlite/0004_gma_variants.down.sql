@@ -0,0 +1,13 @@
+CREATE TABLE gmas_old (
+	id INTEGER NOT NULL,
+	rev INTEGER NOT NULL,
+	fingerprint TEXT NOT NULL,
+	objectkey TEXT NOT NULL,
+	size INTEGER NOT NULL,
+	sha256 TEXT NOT NULL,
+	created DATETIME NOT NULL,
+	PRIMARY KEY (id, rev)
+);
+INSERT INTO gmas_old SELECT id, rev, fingerprint, objectkey, size, sha256, created FROM gmas WHERE variant = '';
+DROP TABLE gmas;
+ALTER TABLE gmas_old RENAME TO gmas;
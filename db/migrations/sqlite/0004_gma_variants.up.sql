@@ -0,0 +1,15 @@
+-- builds with includes merged in are kept next to the plain build of the same revision
+CREATE TABLE gmas_new (
+	id INTEGER NOT NULL,
+	rev INTEGER NOT NULL,
+	variant TEXT NOT NULL DEFAULT '',
+	fingerprint TEXT NOT NULL,
+	objectkey TEXT NOT NULL,
+	size INTEGER NOT NULL,
+	sha256 TEXT NOT NULL,
+	created DATETIME NOT NULL,
+	PRIMARY KEY (id, rev, variant)
+);
+INSERT INTO gmas_new (id, rev, fingerprint, objectkey, size, sha256, created) SELECT id, rev, fingerprint, objectkey, size, sha256, created FROM gmas;
+DROP TABLE gmas;
+ALTER TABLE gmas_new RENAME TO gmas;
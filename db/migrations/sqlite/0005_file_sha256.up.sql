@@ -0,0 +1,2 @@
+-- sha256 of the raw file, the etag of content downloads, filled in the first time it's needed
+ALTER TABLE files ADD COLUMN sha256 TEXT;
@@ -0,0 +1,3 @@
+-- files already moved into blobs can't be read after this
+DROP INDEX files_blob_sha256;
+ALTER TABLE files DROP COLUMN blob_sha256;
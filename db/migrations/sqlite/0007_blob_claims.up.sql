@@ -0,0 +1,10 @@
+-- blobs an import is about to refer to (gc = 0) or gc is about to delete (gc = 1)
+-- both write their claim before looking for the other's, so they can't both go ahead
+CREATE TABLE blob_claims (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	sha256 TEXT NOT NULL,
+	gc BOOLEAN NOT NULL,
+	created DATETIME NOT NULL
+);
+
+CREATE INDEX blob_claims_sha256 ON blob_claims (sha256);
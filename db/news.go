@@ -18,18 +18,25 @@
 
 package db
 
-import "github.com/flatgrassdotnet/cloudbox/common"
+import (
+	"time"
+
+	"github.com/flatgrassdotnet/cloudbox/common"
+	"github.com/flatgrassdotnet/cloudbox/metrics"
+)
+
+func (d *DB) FetchNewsEntries() ([]common.NewsEntry, error) {
+	defer metrics.ObserveQuery("FetchNewsEntries", time.Now())
 
-func FetchNewsEntries() ([]common.NewsEntry, error) {
 	var entries []common.NewsEntry
-	rows, err := handle.Query("SELECT id, title, body, author, time FROM news")
+	rows, err := d.handle.Query("SELECT id, title, body, author, time FROM news")
 	if err != nil {
 		return nil, err
 	}
 
 	for rows.Next() {
 		var entry common.NewsEntry
-		err := rows.Scan(&entry.ID, &entry.Title, &entry.Body, &entry.Author, &entry.Time)
+		err := rows.Scan(&entry.ID, &entry.Title, &entry.Body, &entry.Author, timeValue{&entry.Time})
 		if err != nil {
 			return nil, err
 		}
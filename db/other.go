@@ -18,8 +18,16 @@
 
 package db
 
-func InsertMapLoad(steamid string, duration float64, mapName string, platform string) error {
-	_, err := handle.Exec("INSERT INTO maploads (steamid, duration, map, platform) VALUES (?, ?, ?, ?)", steamid, duration, mapName, platform)
+import (
+	"time"
+
+	"github.com/flatgrassdotnet/cloudbox/metrics"
+)
+
+func (d *DB) InsertMapLoad(steamid string, duration float64, mapName string, platform string) error {
+	defer metrics.ObserveQuery("InsertMapLoad", time.Now())
+
+	_, err := d.handle.Exec("INSERT INTO maploads (steamid, duration, map, platform) VALUES (?, ?, ?, ?)", steamid, duration, mapName, platform)
 	if err != nil {
 		return err
 	}
@@ -27,8 +35,10 @@ func InsertMapLoad(steamid string, duration float64, mapName string, platform st
 	return nil
 }
 
-func InsertError(steamid string, error string, content string, realm string, platform string) error {
-	_, err := handle.Exec("INSERT INTO errors (steamid, error, content, realm, platform) VALUES (?, ?, ?, ?, ?)", steamid, error, content, realm, platform)
+func (d *DB) InsertError(steamid string, error string, content string, realm string, platform string) error {
+	defer metrics.ObserveQuery("InsertError", time.Now())
+
+	_, err := d.handle.Exec("INSERT INTO errors (steamid, error, content, realm, platform) VALUES (?, ?, ?, ?, ?)", steamid, error, content, realm, platform)
 	if err != nil {
 		return err
 	}
@@ -19,13 +19,26 @@
 package db
 
 import (
-	"fmt"
+	"time"
 
 	"github.com/flatgrassdotnet/cloudbox/common"
+	"github.com/flatgrassdotnet/cloudbox/metrics"
 )
 
-func InsertPackage(pkg common.Package) (int, error) {
-	r, err := handle.Exec("INSERT INTO packages (type, name, dataname, author, description, data) VALUES (?, ?, ?, ?, ?, ?)", pkg.Type, pkg.Name, pkg.Dataname, pkg.Author, pkg.Description, pkg.Data)
+func (d *DB) InsertPackage(pkg common.Package) (int, error) {
+	defer metrics.ObserveQuery("InsertPackage", time.Now())
+
+	if d.dia.insertPackageID != "" {
+		var id int
+		err := d.handle.QueryRow("INSERT INTO packages (id, type, name, dataname, author, description, data) VALUES ("+d.dia.insertPackageID+", ?, ?, ?, ?, ?, ?) RETURNING id", pkg.Type, pkg.Name, pkg.Dataname, pkg.Author, pkg.Description, pkg.Data).Scan(&id)
+		if err != nil {
+			return 0, err
+		}
+
+		return id, nil
+	}
+
+	r, err := d.handle.Exec("INSERT INTO packages (type, name, dataname, author, description, data) VALUES (?, ?, ?, ?, ?, ?)", pkg.Type, pkg.Name, pkg.Dataname, pkg.Author, pkg.Description, pkg.Data)
 	if err != nil {
 		return 0, err
 	}
@@ -38,8 +51,10 @@ func InsertPackage(pkg common.Package) (int, error) {
 	return int(i), nil
 }
 
-func InsertPackageInclude(id int, rev int, iid int, irev int) (int, error) {
-	r, err := handle.Exec("INSERT INTO includes (id, rev, includeid, includerev) VALUES (?, ?, ?, ?)", id, rev, iid, irev)
+func (d *DB) InsertPackageInclude(id int, rev int, iid int, irev int) (int, error) {
+	defer metrics.ObserveQuery("InsertPackageInclude", time.Now())
+
+	r, err := d.handle.Exec("INSERT INTO includes (id, rev, includeid, includerev) VALUES (?, ?, ?, ?)", id, rev, iid, irev)
 	if err != nil {
 		return 0, err
 	}
@@ -52,9 +67,11 @@ func InsertPackageInclude(id int, rev int, iid int, irev int) (int, error) {
 	return int(i), nil
 }
 
-func FetchPackageLatestRevision(id int) (int, error) {
+func (d *DB) FetchPackageLatestRevision(id int) (int, error) {
+	defer metrics.ObserveQuery("FetchPackageLatestRevision", time.Now())
+
 	var rev int
-	err := handle.QueryRow("SELECT MAX(rev) FROM packages WHERE id = ?", id).Scan(&rev)
+	err := d.handle.QueryRow("SELECT MAX(rev) FROM packages WHERE id = ?", id).Scan(&rev)
 	if err != nil {
 		return 0, err
 	}
@@ -62,14 +79,16 @@ func FetchPackageLatestRevision(id int) (int, error) {
 	return rev, nil
 }
 
-func FetchPackage(id int, rev int) (common.Package, error) {
+func (d *DB) FetchPackage(id int, rev int) (common.Package, error) {
+	defer metrics.ObserveQuery("FetchPackage", time.Now())
+
 	var pkg common.Package
-	err := handle.QueryRow("SELECT id, rev, type, name, COALESCE(dataname, \"\"), COALESCE(author, \"\"), COALESCE(description, \"\"), data FROM packages WHERE id = ? AND rev = ?", id, rev).Scan(&pkg.ID, &pkg.Revision, &pkg.Type, &pkg.Name, &pkg.Dataname, &pkg.Author, &pkg.Description, &pkg.Data)
+	err := d.handle.QueryRow("SELECT id, rev, type, name, COALESCE(dataname, ''), COALESCE(author, ''), COALESCE(description, ''), data FROM packages WHERE id = ? AND rev = ?", id, rev).Scan(&pkg.ID, &pkg.Revision, &pkg.Type, &pkg.Name, &pkg.Dataname, &pkg.Author, &pkg.Description, &pkg.Data)
 	if err != nil {
 		return pkg, err
 	}
 
-	rows, err := handle.Query("SELECT f.id, f.path, f.size, f.psize FROM files f JOIN content c ON f.id = c.fileid WHERE c.id = ?", id)
+	rows, err := d.handle.Query("SELECT f.id, f.path, f.size, f.psize FROM files f JOIN content c ON f.id = c.fileid WHERE c.id = ?", id)
 	if err != nil {
 		return pkg, err
 	}
@@ -86,7 +105,7 @@ func FetchPackage(id int, rev int) (common.Package, error) {
 		pkg.Content = append(pkg.Content, content)
 	}
 
-	rows, err = handle.Query("SELECT p.id, p.rev, p.type FROM packages p JOIN includes i ON p.id = i.includeid AND p.rev = i.includerev WHERE i.id = ? AND i.rev = ?", id, rev)
+	rows, err = d.handle.Query("SELECT p.id, p.rev, p.type FROM packages p JOIN includes i ON p.id = i.includeid AND p.rev = i.includerev WHERE i.id = ? AND i.rev = ?", id, rev)
 	if err != nil {
 		return pkg, err
 	}
@@ -104,18 +123,20 @@ func FetchPackage(id int, rev int) (common.Package, error) {
 	return pkg, nil
 }
 
-func FetchPackageList(category string, dataname string, author string, search string, offset int, count int, sort string, safemode bool) ([]common.Package, error) {
+func (d *DB) FetchPackageList(category string, dataname string, author string, search string, offset int, count int, sort string, safemode bool) ([]common.Package, error) {
+	defer metrics.ObserveQuery("FetchPackageList", time.Now())
+
 	var args []any
 	q := `SELECT 
 	p.id, 
 	p.rev, 
 	p.type, 
 	p.name, 
-	COALESCE(p.dataname, ""), 
-	COALESCE(p.author, ""), 
-	COALESCE(pr.personaname, s.author, ""), 
-	COALESCE(pr.avatarmedium, ""), 
-	COALESCE(p.description, s.description, ""), 
+	COALESCE(p.dataname, ''), 
+	COALESCE(p.author, ''), 
+	COALESCE(pr.personaname, s.author, ''), 
+	COALESCE(pr.avatarmedium, ''), 
+	COALESCE(p.description, s.description, ''), 
 	COALESCE(s.downloads, 0), 
 	COALESCE(s.favorites, 0), 
 	COALESCE(s.goods, 0), 
@@ -140,7 +161,7 @@ func FetchPackageList(category string, dataname string, author string, search st
 	}
 
 	if search != "" {
-		q += " AND p.name LIKE CONCAT('%', ?, '%')"
+		q += " AND p.name LIKE " + d.dia.concat("'%'", "?", "'%'")
 		args = append(args, search)
 	}
 
@@ -153,9 +174,14 @@ func FetchPackageList(category string, dataname string, author string, search st
 		q += " AND incompatible = 0"
 	}
 
-	// dangerous!
-	if sort != "" {
-		q += fmt.Sprintf(" ORDER BY %s DESC", sort)
+	// sort is one of "newest", "popular" or "random", never user input in the query
+	switch sort {
+	case "newest":
+		q += " ORDER BY p.id DESC"
+	case "popular":
+		q += " ORDER BY COALESCE(s.favorites, 0) DESC"
+	case "random":
+		q += " ORDER BY " + d.dia.random
 	}
 
 	if count != 0 {
@@ -166,14 +192,14 @@ func FetchPackageList(category string, dataname string, author string, search st
 
 	var list []common.Package
 
-	rows, err := handle.Query(q, args...)
+	rows, err := d.handle.Query(q, args...)
 	if err != nil {
 		return list, err
 	}
 
 	for rows.Next() {
 		var pkg common.Package
-		err := rows.Scan(&pkg.ID, &pkg.Revision, &pkg.Type, &pkg.Name, &pkg.Dataname, &pkg.Author, &pkg.AuthorName, &pkg.AuthorIcon, &pkg.Description, &pkg.Downloads, &pkg.Favorites, &pkg.Goods, &pkg.Bads, &pkg.Uploaded)
+		err := rows.Scan(&pkg.ID, &pkg.Revision, &pkg.Type, &pkg.Name, &pkg.Dataname, &pkg.Author, &pkg.AuthorName, &pkg.AuthorIcon, &pkg.Description, &pkg.Downloads, &pkg.Favorites, &pkg.Goods, &pkg.Bads, timeValue{&pkg.Uploaded})
 		if err != nil {
 			return list, err
 		}
@@ -184,7 +210,9 @@ func FetchPackageList(category string, dataname string, author string, search st
 	return list, nil
 }
 
-func FetchPackageListAll(category string, dataname string, author string, search string, offset int, count int, sort string) ([]common.Package, error) {
+func (d *DB) FetchPackageListAll(category string, dataname string, author string, search string, offset int, count int, sort string) ([]common.Package, error) {
+	defer metrics.ObserveQuery("FetchPackageListAll", time.Now())
+
 	var args []any
 	q := `WITH latest_packages AS (
 	SELECT *
@@ -240,7 +268,7 @@ combined AS (
 		s.favorites,
 		s.goods,
 		s.bads,
-		STR_TO_DATE('2012-10-25', '%Y-%c-%d') AS time
+		` + d.dia.releaseDate + ` AS time
 	FROM latest_scraped s
 	WHERE NOT EXISTS (SELECT 1 FROM latest_packages p WHERE p.id = s.id)
 )
@@ -260,7 +288,7 @@ WHERE 1 = 1`
 	}
 
 	if search != "" {
-		q += " AND name LIKE CONCAT('%', ?, '%')"
+		q += " AND name LIKE " + d.dia.concat("'%'", "?", "'%'")
 		args = append(args, search)
 	}
 
@@ -269,9 +297,14 @@ WHERE 1 = 1`
 		args = append(args, dataname)
 	}
 
-	// dangerous!
-	if sort != "" {
-		q += fmt.Sprintf(" ORDER BY %s DESC", sort)
+	// sort is one of "newest", "popular" or "random", never user input in the query
+	switch sort {
+	case "newest":
+		q += " ORDER BY id DESC"
+	case "popular":
+		q += " ORDER BY COALESCE(favorites, 0) DESC"
+	case "random":
+		q += " ORDER BY " + d.dia.random
 	}
 
 	if count != 0 {
@@ -282,14 +315,14 @@ WHERE 1 = 1`
 
 	var list []common.Package
 
-	rows, err := handle.Query(q, args...)
+	rows, err := d.handle.Query(q, args...)
 	if err != nil {
 		return list, err
 	}
 
 	for rows.Next() {
 		var pkg common.Package
-		err := rows.Scan(&pkg.ID, &pkg.Revision, &pkg.Type, &pkg.Name, &pkg.Dataname, &pkg.Author, &pkg.AuthorName, &pkg.AuthorIcon, &pkg.Description, &pkg.Downloads, &pkg.Favorites, &pkg.Goods, &pkg.Bads, &pkg.Uploaded)
+		err := rows.Scan(&pkg.ID, &pkg.Revision, &pkg.Type, &pkg.Name, &pkg.Dataname, &pkg.Author, &pkg.AuthorName, &pkg.AuthorIcon, &pkg.Description, &pkg.Downloads, &pkg.Favorites, &pkg.Goods, &pkg.Bads, timeValue{&pkg.Uploaded})
 		if err != nil {
 			return list, err
 		}
@@ -300,9 +333,11 @@ WHERE 1 = 1`
 	return list, nil
 }
 
-func FetchFileInfoFromPath(path string) (int, error) {
+func (d *DB) FetchFileInfoFromPath(path string) (int, error) {
+	defer metrics.ObserveQuery("FetchFileInfoFromPath", time.Now())
+
 	var id int
-	err := handle.QueryRow("SELECT id FROM files WHERE path = ?", path).Scan(&id)
+	err := d.handle.QueryRow("SELECT id FROM files WHERE path = ?", path).Scan(&id)
 	if err != nil {
 		return 0, err
 	}
@@ -19,11 +19,16 @@
 package db
 
 import (
+	"time"
+
 	"github.com/flatgrassdotnet/cloudbox/common"
+	"github.com/flatgrassdotnet/cloudbox/metrics"
 )
 
-func InsertPlayerSummary(s common.PlayerSummaryInfo) error {
-	_, err := handle.Exec("REPLACE INTO profiles (steamid, personaname, avatar, avatarmedium, avatarfull) VALUES (?, ?, ?, ?, ?)", s.SteamID, s.PersonaName, s.Avatar, s.AvatarMedium, s.AvatarFull)
+func (d *DB) InsertPlayerSummary(s common.PlayerSummaryInfo) error {
+	defer metrics.ObserveQuery("InsertPlayerSummary", time.Now())
+
+	_, err := d.handle.Exec("REPLACE INTO profiles (steamid, personaname, avatar, avatarmedium, avatarfull) VALUES (?, ?, ?, ?, ?)", s.SteamID, s.PersonaName, s.Avatar, s.AvatarMedium, s.AvatarFull)
 	if err != nil {
 		return err
 	}
@@ -31,9 +36,11 @@ func InsertPlayerSummary(s common.PlayerSummaryInfo) error {
 	return nil
 }
 
-func FetchPlayerSummary(steamid string) (common.PlayerSummaryInfo, error) {
+func (d *DB) FetchPlayerSummary(steamid string) (common.PlayerSummaryInfo, error) {
+	defer metrics.ObserveQuery("FetchPlayerSummary", time.Now())
+
 	var s common.PlayerSummaryInfo
-	err := handle.QueryRow("SELECT personaname, avatar, avatarmedium, avatarfull FROM profiles WHERE time > DATE_SUB(UTC_TIMESTAMP(), INTERVAL 1 WEEK) AND steamid = ?", steamid).Scan(&s.PersonaName, &s.Avatar, &s.AvatarMedium, &s.AvatarFull)
+	err := d.handle.QueryRow("SELECT personaname, avatar, avatarmedium, avatarfull FROM profiles WHERE time > "+d.dia.weekAgo+" AND steamid = ?", steamid).Scan(&s.PersonaName, &s.Avatar, &s.AvatarMedium, &s.AvatarFull)
 	if err != nil {
 		return s, err
 	}
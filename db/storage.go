@@ -0,0 +1,228 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/flatgrassdotnet/cloudbox/metrics"
+	"github.com/flatgrassdotnet/cloudbox/storage"
+)
+
+// InitStorage sets up the content and image stores
+// backend is either "s3" or "local", dir is only used by "local"
+// with the local backend each bucket is a subdirectory of dir
+func (d *DB) InitStorage(backend string, dir string, contentBucket string, imageBucket string) error {
+	switch backend {
+	case "s3":
+		cfg, err := config.LoadDefaultConfig(context.TODO())
+		if err != nil {
+			return err
+		}
+
+		client := s3.NewFromConfig(cfg)
+
+		d.content = metrics.InstrumentStore(storage.NewS3Store(client, contentBucket), contentBucket)
+		d.images = metrics.InstrumentStore(storage.NewS3Store(client, imageBucket), imageBucket)
+	case "local":
+		content, err := storage.NewLocalStore(filepath.Join(dir, contentBucket))
+		if err != nil {
+			return err
+		}
+
+		images, err := storage.NewLocalStore(filepath.Join(dir, imageBucket))
+		if err != nil {
+			return err
+		}
+
+		d.content = metrics.InstrumentStore(content, contentBucket)
+		d.images = metrics.InstrumentStore(images, imageBucket)
+	default:
+		return fmt.Errorf("unknown storage backend: %s", backend)
+	}
+
+	return nil
+}
+
+// PingStorage checks that both the content and image stores are reachable
+func (d *DB) PingStorage(ctx context.Context) error {
+	err := d.content.Ping(ctx)
+	if err != nil {
+		return fmt.Errorf("content store: %s", err)
+	}
+
+	err = d.images.Ping(ctx)
+	if err != nil {
+		return fmt.Errorf("image store: %s", err)
+	}
+
+	return nil
+}
+
+func (d *DB) GetContentFile(id int) (*storage.Object, error) {
+	key, err := d.contentKey(id)
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := d.content.Get(context.TODO(), key)
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// contentKey returns where a content file is stored
+// files moved into blobs are stored under their hash, older ones under their id
+func (d *DB) contentKey(id int) (string, error) {
+	var blob sql.NullString
+	err := d.handle.QueryRow("SELECT blob_sha256 FROM files WHERE id = ?", id).Scan(&blob)
+	if err != nil {
+		return "", err
+	}
+
+	if blob.Valid {
+		return blobKey(blob.String), nil
+	}
+
+	return strconv.Itoa(id), nil
+}
+
+// StatContentFile returns the size and modification time of a content file without reading it
+func (d *DB) StatContentFile(id int) (storage.ObjectInfo, error) {
+	key, err := d.contentKey(id)
+	if err != nil {
+		return storage.ObjectInfo{}, err
+	}
+
+	return d.content.Stat(context.TODO(), key)
+}
+
+// OpenContentFile returns a reader for a content file found by StatContentFile, it fetches lazily and supports seeking
+// ranges are passed through to the content store
+func (d *DB) OpenContentFile(ctx context.Context, info storage.ObjectInfo) io.ReadSeekCloser {
+	return storage.NewReadSeeker(ctx, d.content, info.Key, info.Size)
+}
+
+// FetchFileCRC returns the crc32 of a content file
+func (d *DB) FetchFileCRC(id int) (uint32, error) {
+	defer metrics.ObserveQuery("FetchFileCRC", time.Now())
+
+	crc, _, err := d.fileSums(id)
+	if err != nil {
+		return 0, err
+	}
+
+	return crc, nil
+}
+
+// FetchFileSHA256 returns the hex sha256 of a content file
+func (d *DB) FetchFileSHA256(id int) (string, error) {
+	defer metrics.ObserveQuery("FetchFileSHA256", time.Now())
+
+	_, sum, err := d.fileSums(id)
+	if err != nil {
+		return "", err
+	}
+
+	return sum, nil
+}
+
+// FetchFileSums returns the stored checksums of a content file, ok is false if they haven't been computed yet
+func (d *DB) FetchFileSums(id int) (uint32, string, bool, error) {
+	defer metrics.ObserveQuery("FetchFileSums", time.Now())
+
+	var crc sql.NullInt64
+	var sum sql.NullString
+	err := d.handle.QueryRow("SELECT crc, sha256 FROM files WHERE id = ?", id).Scan(&crc, &sum)
+	if err != nil {
+		return 0, "", false, err
+	}
+
+	if !crc.Valid || !sum.Valid {
+		return 0, "", false, nil
+	}
+
+	return uint32(crc.Int64), sum.String, true, nil
+}
+
+// StoreFileSums caches the checksums of a content file that was hashed while being served
+func (d *DB) StoreFileSums(id int, crc uint32, sum string) error {
+	defer metrics.ObserveQuery("StoreFileSums", time.Now())
+
+	_, err := d.handle.Exec("UPDATE files SET crc = ?, sha256 = ? WHERE id = ?", crc, sum, id)
+
+	return err
+}
+
+// fileSums returns the checksums of a content file
+// they're computed by streaming the file from the content store the first time either is needed and cached in the files table
+func (d *DB) fileSums(id int) (uint32, string, error) {
+	crc, sum, ok, err := d.FetchFileSums(id)
+	if err != nil || ok {
+		return crc, sum, err
+	}
+
+	o, err := d.GetContentFile(id)
+	if err != nil {
+		return 0, "", err
+	}
+
+	defer o.Body.Close()
+
+	c := crc32.NewIEEE()
+	s := sha256.New()
+	_, err = io.Copy(io.MultiWriter(c, s), o.Body)
+	if err != nil {
+		return 0, "", err
+	}
+
+	crc, sum = c.Sum32(), hex.EncodeToString(s.Sum(nil))
+
+	err = d.StoreFileSums(id, crc, sum)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return crc, sum, nil
+}
+
+func (d *DB) PutThumbnail(id int, data io.Reader) error {
+	err := d.images.Put(context.TODO(), fmt.Sprintf("%d_thumb_128.png", id), data, storage.PutOptions{
+		ContentType: "image/png",
+		Public:      true,
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// timeValue scans timestamps from both mysql (time.Time) and sqlite (text for computed columns)
+type timeValue struct {
+	t *time.Time
+}
+
+var timeLayouts = []string{
+	time.DateTime,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	time.RFC3339Nano,
+	time.DateOnly,
+}
+
+func (v timeValue) Scan(src any) error {
+	var s string
+	switch data := src.(type) {
+	case nil:
+		*v.t = time.Time{}
+		return nil
+	case time.Time:
+		*v.t = data
+		return nil
+	case string:
+		s = data
+	case []byte:
+		s = string(data)
+	default:
+		return fmt.Errorf("unsupported time value type %T", src)
+	}
+
+	for _, layout := range timeLayouts {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			*v.t = t
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unsupported time value format: %s", s)
+}
@@ -20,14 +20,18 @@ package db
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/flatgrassdotnet/cloudbox/common"
+	"github.com/flatgrassdotnet/cloudbox/metrics"
 )
 
-func InsertUpload(steamid int, upload common.Upload) (int, error) {
+func (d *DB) InsertUpload(steamid int, upload common.Upload) (int, error) {
+	defer metrics.ObserveQuery("InsertUpload", time.Now())
+
 	includes, _ := json.Marshal(upload.Includes)
 
-	r, err := handle.Exec("INSERT INTO uploads (steamid, type, meta, includes, data) VALUES (?, ?, ?, ?, ?)", steamid, upload.Type, upload.Metadata, includes, upload.Data)
+	r, err := d.handle.Exec("INSERT INTO uploads (steamid, type, meta, includes, data) VALUES (?, ?, ?, ?, ?)", steamid, upload.Type, upload.Metadata, includes, upload.Data)
 	if err != nil {
 		return 0, err
 	}
@@ -40,10 +44,12 @@ func InsertUpload(steamid int, upload common.Upload) (int, error) {
 	return int(i), nil
 }
 
-func FetchUpload(id int) (common.Upload, error) {
+func (d *DB) FetchUpload(id int) (common.Upload, error) {
+	defer metrics.ObserveQuery("FetchUpload", time.Now())
+
 	var upload common.Upload
 	var includes string
-	err := handle.QueryRow("SELECT type, meta, includes, data FROM uploads WHERE id = ?", id).Scan(&upload.Type, &upload.Metadata, &includes, &upload.Data)
+	err := d.handle.QueryRow("SELECT type, meta, includes, data FROM uploads WHERE id = ?", id).Scan(&upload.Type, &upload.Metadata, &includes, &upload.Data)
 	if err != nil {
 		return upload, err
 	}
@@ -53,8 +59,10 @@ func FetchUpload(id int) (common.Upload, error) {
 	return upload, nil
 }
 
-func DeleteUpload(id int) error {
-	_, err := handle.Exec("DELETE FROM uploads WHERE id = ?", id)
+func (d *DB) DeleteUpload(id int) error {
+	defer metrics.ObserveQuery("DeleteUpload", time.Now())
+
+	_, err := d.handle.Exec("DELETE FROM uploads WHERE id = ?", id)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,120 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package deps defines what handlers need from the outside world
+// main fills Deps with the real implementations, tests use deps/fake instead
+package deps
+
+import (
+	"context"
+	"io"
+
+	"github.com/flatgrassdotnet/cloudbox/common"
+	"github.com/flatgrassdotnet/cloudbox/events"
+	"github.com/flatgrassdotnet/cloudbox/storage"
+)
+
+// Deps holds everything handlers need from the outside world
+// main fills it with the real database, steam and event bus implementations
+type Deps struct {
+	Packages PackageStore
+	Uploads  UploadStore
+	Sessions SessionStore
+	Stats    StatsStore
+	News     NewsStore
+	Files    FileStore
+	GMAs     GMAStore
+	Steam    SteamClient
+	Events   Publisher
+}
+
+// Handler is shared by the handler packages under api/ and ingame/,
+// each declares its own with "type Handler deps.Handler" and adds one method per route
+type Handler struct {
+	Deps
+}
+
+// New builds a handler package's Handler, as in deps.New[auth.Handler](d)
+func New[H ~struct{ Deps }](d Deps) *H {
+	return &H{d}
+}
+
+type PackageStore interface {
+	InsertPackage(pkg common.Package) (int, error)
+	InsertPackageInclude(id int, rev int, iid int, irev int) (int, error)
+	FetchPackageLatestRevision(id int) (int, error)
+	FetchPackage(id int, rev int) (common.Package, error)
+
+	// sort is one of "newest", "popular" or "random"
+	FetchPackageList(category string, dataname string, author string, search string, offset int, count int, sort string, safemode bool) ([]common.Package, error)
+	FetchPackageListAll(category string, dataname string, author string, search string, offset int, count int, sort string) ([]common.Package, error)
+
+	FetchFileInfoFromPath(path string) (int, error)
+}
+
+type UploadStore interface {
+	InsertUpload(steamid int, upload common.Upload) (int, error)
+	FetchUpload(id int) (common.Upload, error)
+	DeleteUpload(id int) error
+}
+
+type SessionStore interface {
+	InsertLogin(steamid string, vac string, ticket []byte) error
+	FetchSteamIDFromTicket(ticket []byte) (string, error)
+}
+
+type StatsStore interface {
+	InsertMapLoad(steamid string, duration float64, mapName string, platform string) error
+	InsertError(steamid string, error string, content string, realm string, platform string) error
+}
+
+type NewsStore interface {
+	FetchNewsEntries() ([]common.NewsEntry, error)
+}
+
+// FileStore holds package content and thumbnails
+type FileStore interface {
+	GetContentFile(id int) (*storage.Object, error)
+	StatContentFile(id int) (storage.ObjectInfo, error)
+	OpenContentFile(ctx context.Context, info storage.ObjectInfo) io.ReadSeekCloser
+	FetchFileCRC(id int) (uint32, error)
+	FetchFileSHA256(id int) (string, error)
+
+	// FetchFileSums only returns checksums already stored, ok is false for files nothing has hashed yet
+	// unlike FetchFileCRC and FetchFileSHA256 it never reads the file
+	FetchFileSums(id int) (crc uint32, sum string, ok bool, err error)
+	StoreFileSums(id int, crc uint32, sum string) error
+	PutThumbnail(id int, data io.Reader) error
+}
+
+// GMAStore caches built gmas
+type GMAStore interface {
+	FetchGMAArtifact(id int, rev int, variant string) (common.GMAArtifact, error)
+	StoreGMAArtifact(a common.GMAArtifact, body io.Reader) (common.GMAArtifact, error)
+	OpenGMAArtifact(ctx context.Context, a common.GMAArtifact) io.ReadSeekCloser
+}
+
+type SteamClient interface {
+	AuthenticateUserTicket(ticket string) (common.UserTicketInfo, error)
+	GetPlayerSummaries(steamids ...string) ([]common.PlayerSummaryInfo, error)
+}
+
+// Publisher hands events to the configured sinks, it must not block
+type Publisher interface {
+	Publish(e events.Event)
+}
@@ -0,0 +1,536 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package fake provides in-memory implementations of the handler dependencies
+// for use in tests and local development
+package fake
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flatgrassdotnet/cloudbox/common"
+	"github.com/flatgrassdotnet/cloudbox/deps"
+	"github.com/flatgrassdotnet/cloudbox/events"
+	"github.com/flatgrassdotnet/cloudbox/storage"
+)
+
+type MapLoad struct {
+	SteamID  string
+	Duration float64
+	Map      string
+	Platform string
+}
+
+type LuaError struct {
+	SteamID  string
+	Error    string
+	Content  string
+	Realm    string
+	Platform string
+}
+
+type File struct {
+	Path string
+	Data []byte
+
+	// stored checksums, FetchFileSums reports none while SHA256 is empty
+	CRC    uint32
+	SHA256 string
+}
+
+type GMA struct {
+	common.GMAArtifact
+	Data []byte
+}
+
+// GMAKey identifies a stored gma build
+type GMAKey struct {
+	ID       int
+	Revision int
+	Variant  string
+}
+
+type include struct {
+	id, rev, iid, irev int
+}
+
+// Store implements every store interface in deps
+// missing rows return sql.ErrNoRows like the real database
+type Store struct {
+	mu sync.Mutex
+
+	Packages   []common.Package
+	Files      map[int]File
+	Thumbnails map[int][]byte
+	GMAs       map[GMAKey]GMA
+	Uploads    map[int]common.Upload
+	Logins     map[string][]byte // steamid -> ticket
+	MapLoads   []MapLoad
+	Errors     []LuaError
+	News       []common.NewsEntry
+
+	includes     []include
+	lastUploadID int
+}
+
+func NewStore() *Store {
+	return &Store{
+		Files:      make(map[int]File),
+		Thumbnails: make(map[int][]byte),
+		GMAs:       make(map[GMAKey]GMA),
+		Uploads:    make(map[int]common.Upload),
+		Logins:     make(map[string][]byte),
+	}
+}
+
+// Deps returns a deps.Deps backed entirely by fakes
+func Deps(s *Store, steam *Steam, p *Publisher) deps.Deps {
+	return deps.Deps{
+		Packages: s,
+		Uploads:  s,
+		Sessions: s,
+		Stats:    s,
+		News:     s,
+		Files:    s,
+		GMAs:     s,
+		Steam:    steam,
+		Events:   p,
+	}
+}
+
+func (s *Store) InsertPackage(pkg common.Package) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pkg.ID = 1
+	for _, p := range s.Packages {
+		pkg.ID = max(pkg.ID, p.ID+1)
+	}
+
+	pkg.Revision = 1
+	pkg.Uploaded = time.Now().UTC()
+
+	s.Packages = append(s.Packages, pkg)
+
+	return pkg.ID, nil
+}
+
+func (s *Store) InsertPackageInclude(id int, rev int, iid int, irev int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.includes = append(s.includes, include{id, rev, iid, irev})
+
+	return 0, nil
+}
+
+func (s *Store) FetchPackageLatestRevision(id int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rev := 0
+	for _, p := range s.Packages {
+		if p.ID == id {
+			rev = max(rev, p.Revision)
+		}
+	}
+
+	if rev == 0 {
+		return 0, sql.ErrNoRows
+	}
+
+	return rev, nil
+}
+
+func (s *Store) FetchPackage(id int, rev int) (common.Package, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range s.Packages {
+		if p.ID != id || p.Revision != rev {
+			continue
+		}
+
+		for _, i := range s.includes {
+			if i.id != id || i.rev != rev {
+				continue
+			}
+
+			for _, ip := range s.Packages {
+				if ip.ID == i.iid && ip.Revision == i.irev {
+					p.Includes = append(p.Includes, common.Include{ID: ip.ID, Revision: ip.Revision, Type: ip.Type})
+				}
+			}
+		}
+
+		return p, nil
+	}
+
+	return common.Package{}, sql.ErrNoRows
+}
+
+func (s *Store) FetchPackageList(category string, dataname string, author string, search string, offset int, count int, sort string, safemode bool) ([]common.Package, error) {
+	return s.FetchPackageListAll(category, dataname, author, search, offset, count, sort)
+}
+
+func (s *Store) FetchPackageListAll(category string, dataname string, author string, search string, offset int, count int, sort string) ([]common.Package, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// latest revisions only
+	latest := make(map[int]common.Package)
+	for _, p := range s.Packages {
+		if l, ok := latest[p.ID]; !ok || p.Revision > l.Revision {
+			latest[p.ID] = p
+		}
+	}
+
+	var list []common.Package
+	for _, p := range latest {
+		if category != "" && p.Type != category {
+			continue
+		}
+
+		if dataname != "" && p.Dataname != dataname {
+			continue
+		}
+
+		if author != "" && p.Author != author {
+			continue
+		}
+
+		if search != "" && !strings.Contains(strings.ToLower(p.Name), strings.ToLower(search)) {
+			continue
+		}
+
+		p.Content = nil
+		p.Includes = nil
+		p.Data = nil
+
+		list = append(list, p)
+	}
+
+	switch sort {
+	case "popular":
+		slices.SortFunc(list, func(a, b common.Package) int { return b.Favorites - a.Favorites })
+	case "random":
+		rand.Shuffle(len(list), func(i, j int) { list[i], list[j] = list[j], list[i] })
+	default:
+		slices.SortFunc(list, func(a, b common.Package) int { return b.ID - a.ID })
+	}
+
+	if count != 0 {
+		list = list[min(offset, len(list)):min(offset+count, len(list))]
+	}
+
+	return list, nil
+}
+
+func (s *Store) FetchFileInfoFromPath(path string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, f := range s.Files {
+		if f.Path == path {
+			return id, nil
+		}
+	}
+
+	return 0, sql.ErrNoRows
+}
+
+func (s *Store) InsertUpload(steamid int, upload common.Upload) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastUploadID++
+	s.Uploads[s.lastUploadID] = upload
+
+	return s.lastUploadID, nil
+}
+
+func (s *Store) FetchUpload(id int) (common.Upload, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	upload, ok := s.Uploads[id]
+	if !ok {
+		return common.Upload{}, sql.ErrNoRows
+	}
+
+	return upload, nil
+}
+
+func (s *Store) DeleteUpload(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.Uploads, id)
+
+	return nil
+}
+
+func (s *Store) InsertLogin(steamid string, vac string, ticket []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Logins[steamid] = ticket
+
+	return nil
+}
+
+func (s *Store) FetchSteamIDFromTicket(ticket []byte) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for steamid, t := range s.Logins {
+		if bytes.Equal(t, ticket) {
+			return steamid, nil
+		}
+	}
+
+	return "", sql.ErrNoRows
+}
+
+func (s *Store) InsertMapLoad(steamid string, duration float64, mapName string, platform string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.MapLoads = append(s.MapLoads, MapLoad{steamid, duration, mapName, platform})
+
+	return nil
+}
+
+func (s *Store) InsertError(steamid string, error string, content string, realm string, platform string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Errors = append(s.Errors, LuaError{steamid, error, content, realm, platform})
+
+	return nil
+}
+
+func (s *Store) FetchNewsEntries() ([]common.NewsEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.News), nil
+}
+
+func (s *Store) GetContentFile(id int) (*storage.Object, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.Files[id]
+	if !ok {
+		return nil, storage.ErrNotExist
+	}
+
+	return &storage.Object{
+		ObjectInfo: storage.ObjectInfo{Size: int64(len(f.Data))},
+		Body:       io.NopCloser(bytes.NewReader(f.Data)),
+	}, nil
+}
+
+func (s *Store) StatContentFile(id int) (storage.ObjectInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.Files[id]
+	if !ok {
+		return storage.ObjectInfo{}, storage.ErrNotExist
+	}
+
+	return storage.ObjectInfo{Key: strconv.Itoa(id), Size: int64(len(f.Data))}, nil
+}
+
+func (s *Store) OpenContentFile(ctx context.Context, info storage.ObjectInfo) io.ReadSeekCloser {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, _ := strconv.Atoi(info.Key)
+
+	return nopCloser{bytes.NewReader(s.Files[id].Data)}
+}
+
+func (s *Store) FetchFileSHA256(id int) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.Files[id]
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+
+	sum := sha256.Sum256(f.Data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (s *Store) FetchFileCRC(id int) (uint32, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.Files[id]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+
+	return crc32.ChecksumIEEE(f.Data), nil
+}
+
+func (s *Store) FetchFileSums(id int) (uint32, string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.Files[id]
+	if !ok {
+		return 0, "", false, sql.ErrNoRows
+	}
+
+	return f.CRC, f.SHA256, f.SHA256 != "", nil
+}
+
+func (s *Store) StoreFileSums(id int, crc uint32, sum string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.Files[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	f.CRC, f.SHA256 = crc, sum
+	s.Files[id] = f
+
+	return nil
+}
+
+func (s *Store) FetchGMAArtifact(id int, rev int, variant string) (common.GMAArtifact, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.GMAs[GMAKey{id, rev, variant}]
+	if !ok {
+		return common.GMAArtifact{}, sql.ErrNoRows
+	}
+
+	return a.GMAArtifact, nil
+}
+
+func (s *Store) StoreGMAArtifact(a common.GMAArtifact, body io.Reader) (common.GMAArtifact, error) {
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return common.GMAArtifact{}, err
+	}
+
+	a.Key = fmt.Sprintf("gma/%d_%d_%s.gma", a.ID, a.Revision, a.Fingerprint)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.GMAs[GMAKey{a.ID, a.Revision, a.Variant}] = GMA{GMAArtifact: a, Data: b}
+
+	return a, nil
+}
+
+func (s *Store) OpenGMAArtifact(ctx context.Context, a common.GMAArtifact) io.ReadSeekCloser {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return nopCloser{bytes.NewReader(s.GMAs[GMAKey{a.ID, a.Revision, a.Variant}].Data)}
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+func (s *Store) PutThumbnail(id int, data io.Reader) error {
+	b, err := io.ReadAll(data)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Thumbnails[id] = b
+
+	return nil
+}
+
+// Steam accepts any ticket listed in Tickets
+type Steam struct {
+	Tickets   map[string]string // ticket -> steamid
+	Summaries map[string]common.PlayerSummaryInfo
+}
+
+func (s *Steam) AuthenticateUserTicket(ticket string) (common.UserTicketInfo, error) {
+	steamid, ok := s.Tickets[ticket]
+	if !ok {
+		return common.UserTicketInfo{}, errors.New("Invalid ticket")
+	}
+
+	return common.UserTicketInfo{Result: "OK", SteamID: steamid, OwnerSteamID: steamid}, nil
+}
+
+func (s *Steam) GetPlayerSummaries(steamids ...string) ([]common.PlayerSummaryInfo, error) {
+	var summaries []common.PlayerSummaryInfo
+	for _, steamid := range steamids {
+		summary, ok := s.Summaries[steamid]
+		if !ok {
+			summary = common.PlayerSummaryInfo{SteamID: steamid, PersonaName: steamid}
+		}
+
+		summaries = append(summaries, summary)
+	}
+
+	return summaries, nil
+}
+
+// Publisher records every event it is asked to publish
+type Publisher struct {
+	mu sync.Mutex
+
+	Events []events.Event
+}
+
+func (p *Publisher) Publish(e events.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.Events = append(p.Events, e)
+}
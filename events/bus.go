@@ -0,0 +1,81 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package events
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+)
+
+// Sink delivers events somewhere, Send must not block the caller
+type Sink interface {
+	Send(e Envelope)
+
+	// Close delivers what's still pending, giving up when ctx expires
+	Close(ctx context.Context) error
+}
+
+type subscription struct {
+	sink  Sink
+	types []Type // empty for all
+}
+
+// Bus fans published events out to the sinks subscribed to them
+// subscribe everything before publishing, subscriptions aren't synchronized
+type Bus struct {
+	subs []subscription
+}
+
+// Subscribe sends events of the given types to s, or all events if none are given
+func (b *Bus) Subscribe(s Sink, types ...Type) {
+	b.subs = append(b.subs, subscription{sink: s, types: types})
+}
+
+func (b *Bus) Publish(e Event) {
+	env := newEnvelope(e)
+
+	for _, sub := range b.subs {
+		if len(sub.types) != 0 && !slices.Contains(sub.types, env.Type) {
+			continue
+		}
+
+		sub.sink.Send(env)
+	}
+}
+
+// Close closes every subscribed sink concurrently
+func (b *Bus) Close(ctx context.Context) error {
+	errs := make([]error, len(b.subs))
+
+	var wg sync.WaitGroup
+	for i, sub := range b.subs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			errs[i] = sub.sink.Close(ctx)
+		}()
+	}
+
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,104 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package events
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/flatgrassdotnet/cloudbox/utils"
+)
+
+// DiscordSink turns events into embeds for a discord webhook queue
+type DiscordSink struct {
+	queue *utils.DiscordQueue
+}
+
+func NewDiscordSink(q *utils.DiscordQueue) *DiscordSink {
+	return &DiscordSink{queue: q}
+}
+
+func (s *DiscordSink) Send(e Envelope) {
+	s.queue.Enqueue(discordEmbed(e.Data))
+}
+
+func (s *DiscordSink) Close(ctx context.Context) error {
+	return s.queue.Close(ctx)
+}
+
+func discordEmbed(e Event) utils.DiscordWebhookEmbed {
+	switch e := e.(type) {
+	case Login:
+		return utils.DiscordWebhookEmbed{
+			Title: "Login",
+			Color: 0x4096EE,
+			Author: utils.DiscordWebhookEmbedAuthor{
+				Name:    e.Name,
+				IconURL: e.Avatar,
+			},
+		}
+	case PackageDownload:
+		return utils.DiscordWebhookEmbed{
+			Title:       "Package Download",
+			Description: fmt.Sprintf("%s (%dr%d/%s)", e.Name, e.PackageID, e.Revision, e.PackageType),
+			Color:       0x4096EE,
+			Author: utils.DiscordWebhookEmbedAuthor{
+				Name: e.SteamID,
+			},
+			Image: utils.DiscordWebhookEmbedImage{
+				URL: e.Thumbnail,
+			},
+		}
+	case MapLoad:
+		return utils.DiscordWebhookEmbed{
+			Title:       "Map Load",
+			Description: e.Map,
+			Color:       0x4096EE,
+			Author: utils.DiscordWebhookEmbedAuthor{
+				Name: e.SteamID,
+			},
+		}
+	case LuaError:
+		return utils.DiscordWebhookEmbed{
+			Title:       "Lua Error",
+			Description: e.Error,
+			Color:       0x00007D,
+			Author: utils.DiscordWebhookEmbedAuthor{
+				Name: e.SteamID,
+			},
+		}
+	case SavePublished:
+		return utils.DiscordWebhookEmbed{
+			Title:       e.Name,
+			Description: e.Description,
+			Color:       0xB8E3FF,
+			Author: utils.DiscordWebhookEmbedAuthor{
+				Name:    e.Author,
+				IconURL: e.Avatar,
+			},
+			Image: utils.DiscordWebhookEmbedImage{
+				URL: e.Thumbnail,
+			},
+		}
+	}
+
+	title, text := summary(e)
+
+	return utils.DiscordWebhookEmbed{Title: title, Description: text}
+}
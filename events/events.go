@@ -0,0 +1,119 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+type Type string
+
+const (
+	TypeLogin           Type = "login"
+	TypePackageDownload Type = "package_download"
+	TypeMapLoad         Type = "map_load"
+	TypeLuaError        Type = "lua_error"
+	TypeSavePublished   Type = "save_published"
+)
+
+var Types = []Type{TypeLogin, TypePackageDownload, TypeMapLoad, TypeLuaError, TypeSavePublished}
+
+// ParseType validates an event type name
+func ParseType(s string) (Type, error) {
+	for _, t := range Types {
+		if string(t) == s {
+			return t, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown event type: %s", s)
+}
+
+// Event is implemented by every event payload
+type Event interface {
+	Type() Type
+}
+
+type Login struct {
+	SteamID string `json:"steamid"`
+	Name    string `json:"name"`
+	Avatar  string `json:"avatar"`
+}
+
+type PackageDownload struct {
+	SteamID     string `json:"steamid"`
+	PackageID   int    `json:"package_id"`
+	Revision    int    `json:"revision"`
+	PackageType string `json:"package_type"`
+	Name        string `json:"name"`
+	Thumbnail   string `json:"thumbnail"`
+}
+
+type MapLoad struct {
+	SteamID  string  `json:"steamid"`
+	Map      string  `json:"map"`
+	Duration float64 `json:"duration"` // seconds
+	Platform string  `json:"platform"`
+}
+
+type LuaError struct {
+	SteamID  string `json:"steamid"`
+	Error    string `json:"error"`
+	Realm    string `json:"realm"`
+	Platform string `json:"platform"`
+}
+
+type SavePublished struct {
+	SteamID     string `json:"steamid"`
+	Author      string `json:"author"`
+	Avatar      string `json:"avatar"`
+	PackageID   int    `json:"package_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Thumbnail   string `json:"thumbnail"`
+}
+
+func (Login) Type() Type           { return TypeLogin }
+func (PackageDownload) Type() Type { return TypePackageDownload }
+func (MapLoad) Type() Type         { return TypeMapLoad }
+func (LuaError) Type() Type        { return TypeLuaError }
+func (SavePublished) Type() Type   { return TypeSavePublished }
+
+// Envelope is what sinks receive, Data holds one of the event types above
+type Envelope struct {
+	ID   string    `json:"id"`
+	Type Type      `json:"type"`
+	Time time.Time `json:"time"`
+	Data Event     `json:"data"`
+}
+
+func newEnvelope(e Event) Envelope {
+	b := make([]byte, 8)
+	rand.Read(b)
+
+	return Envelope{
+		ID:   hex.EncodeToString(b),
+		Type: e.Type(),
+		Time: time.Now().UTC(),
+		Data: e,
+	}
+}
@@ -16,41 +16,48 @@
 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
 */
 
-package db
+package events
 
 import (
 	"context"
-	"fmt"
-	"io"
-	"strconv"
-
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"encoding/json"
+	"errors"
+	"os"
 )
 
-func GetContentFile(id int) (*s3.GetObjectOutput, error) {
-	o, err := s3client.GetObject(context.TODO(), &s3.GetObjectInput{
-		Bucket: aws.String("flatgrass-toybox-content"),
-		Key:    aws.String(strconv.Itoa(id)),
-	})
+// fileSink appends events to a file as newline delimited json
+// writes happen on the queue's goroutine so a slow disk never holds up a request
+type fileSink struct {
+	*queue
+
+	f *os.File
+}
+
+func newFileSink(c SinkConfig) (*fileSink, error) {
+	f, err := os.OpenFile(c.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
 	if err != nil {
 		return nil, err
 	}
 
-	return o, nil
+	s := &fileSink{f: f}
+	s.queue = newQueue(c.Name, c.Queue, s.write)
+
+	return s, nil
 }
 
-func PutThumbnail(id int, data io.Reader) error {
-	_, err := s3client.PutObject(context.TODO(), &s3.PutObjectInput{
-		Bucket:         aws.String("flatgrass-toybox-image"),
-		Key:            aws.String(fmt.Sprintf("%d_thumb_128.png", id)),
-		ACL:            types.ObjectCannedACLPublicRead,
-		Body:           data,
-	})
+func (s *fileSink) write(ctx context.Context, e Envelope) error {
+	line, err := json.Marshal(e)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	// one write per line so lines from concurrent processes don't interleave
+	_, err = s.f.Write(append(line, '\n'))
+
+	return err
+}
+
+// Close writes what's still queued, then closes the file
+func (s *fileSink) Close(ctx context.Context) error {
+	return errors.Join(s.queue.Close(ctx), s.f.Close())
 }
@@ -0,0 +1,207 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxAttempts = 5
+	maxWait     = time.Minute
+)
+
+var client = &http.Client{Timeout: 15 * time.Second}
+
+// httpSink posts events as json to a generic webhook, a slack incoming webhook
+// or a matrix generic webhook (hookshot)
+//
+// generic webhooks get the Envelope itself, signed when a secret is set:
+// X-Cloudbox-Signature is "sha256=" followed by the hex HMAC-SHA256 of
+// X-Cloudbox-Timestamp, a dot and the body
+type httpSink struct {
+	*queue
+
+	url    string
+	secret string
+	format string
+}
+
+func newHTTPSink(c SinkConfig) *httpSink {
+	s := &httpSink{url: c.URL, secret: c.Secret, format: c.Type}
+	s.queue = newQueue(c.Name, c.Queue, s.deliver)
+
+	return s
+}
+
+func (s *httpSink) body(e Envelope) ([]byte, error) {
+	switch s.format {
+	case "slack":
+		title, text := summary(e.Data)
+
+		return json.Marshal(map[string]string{
+			"text": fmt.Sprintf("*%s*\n%s", slackEscape(title), slackEscape(text)),
+		})
+	case "matrix":
+		title, text := summary(e.Data)
+
+		return json.Marshal(map[string]string{
+			"text": title + "\n" + text,
+			"html": fmt.Sprintf("<strong>%s</strong><br>%s", html.EscapeString(title), strings.ReplaceAll(html.EscapeString(text), "\n", "<br>")),
+		})
+	}
+
+	return json.Marshal(e)
+}
+
+// deliver posts e, retrying rate limited and failed requests with backoff
+func (s *httpSink) deliver(ctx context.Context, e Envelope) error {
+	body, err := s.body(e)
+	if err != nil {
+		return err
+	}
+
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		wait, err := s.post(ctx, e, body)
+		if err == nil {
+			return nil
+		}
+
+		if wait < 0 || attempt == maxAttempts {
+			return err
+		}
+
+		if wait == 0 {
+			wait = backoff
+			backoff *= 2
+		}
+
+		t := time.NewTimer(min(wait, maxWait))
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		}
+	}
+}
+
+// post sends one request
+// wait is how long the server asked us to wait before retrying, or -1 if retrying won't help
+func (s *httpSink) post(ctx context.Context, e Envelope, body []byte) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return -1, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if s.format == "webhook" {
+		req.Header.Set("X-Cloudbox-Event", string(e.Type))
+		req.Header.Set("X-Cloudbox-Delivery", e.ID)
+
+		if s.secret != "" {
+			ts := strconv.FormatInt(time.Now().Unix(), 10)
+
+			req.Header.Set("X-Cloudbox-Timestamp", ts)
+			req.Header.Set("X-Cloudbox-Signature", "sha256="+sign(s.secret, ts, body))
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	defer resp.Body.Close()
+
+	// drain so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return retryAfter(resp.Header), fmt.Errorf("rate limited")
+	case resp.StatusCode >= 500:
+		return 0, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	case resp.StatusCode >= 300:
+		return -1, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return 0, nil
+}
+
+func sign(secret string, ts string, body []byte) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(ts + "."))
+	m.Write(body)
+
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// retryAfter parses a Retry-After header given in seconds or as a date
+func retryAfter(h http.Header) time.Duration {
+	v := h.Get("Retry-After")
+
+	s, err := strconv.ParseFloat(v, 64)
+	if err == nil && s > 0 {
+		return time.Duration(s * float64(time.Second))
+	}
+
+	t, err := http.ParseTime(v)
+	if err == nil {
+		return max(time.Until(t), 0)
+	}
+
+	return 0
+}
+
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// summary describes an event in plain text for chat sinks
+func summary(e Event) (string, string) {
+	switch e := e.(type) {
+	case Login:
+		return "Login", fmt.Sprintf("%s (%s)", e.Name, e.SteamID)
+	case PackageDownload:
+		return "Package Download", fmt.Sprintf("%s downloaded %s (%dr%d/%s)", e.SteamID, e.Name, e.PackageID, e.Revision, e.PackageType)
+	case MapLoad:
+		return "Map Load", fmt.Sprintf("%s loaded %s in %.1fs on %s", e.SteamID, e.Map, e.Duration, e.Platform)
+	case LuaError:
+		return "Lua Error", fmt.Sprintf("%s (%s, %s): %s", e.SteamID, e.Realm, e.Platform, e.Error)
+	case SavePublished:
+		return "Save Published", fmt.Sprintf("%s by %s\n%s\n%s", e.Name, e.Author, e.Description, e.Thumbnail)
+	}
+
+	return string(e.Type()), ""
+}
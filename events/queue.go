@@ -0,0 +1,115 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/flatgrassdotnet/cloudbox/metrics"
+)
+
+// queue delivers envelopes one at a time from a background goroutine
+type queue struct {
+	name string
+	send func(ctx context.Context, e Envelope) error
+
+	mu     sync.RWMutex
+	closed bool
+	ch     chan Envelope
+	done   chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newQueue(name string, size int, send func(ctx context.Context, e Envelope) error) *queue {
+	q := &queue{
+		name: name,
+		send: send,
+		ch:   make(chan Envelope, size),
+		done: make(chan struct{}),
+	}
+
+	q.ctx, q.cancel = context.WithCancel(context.Background())
+
+	go q.run()
+
+	return q
+}
+
+// Send queues e without blocking, it's dropped if the queue is full
+func (q *queue) Send(e Envelope) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		metrics.Events.WithLabelValues(q.name, string(e.Type), "dropped").Inc()
+		return
+	}
+
+	select {
+	case q.ch <- e:
+	default:
+		metrics.Events.WithLabelValues(q.name, string(e.Type), "dropped").Inc()
+		slog.Warn("event queue full, dropping event", "sink", q.name, "type", e.Type)
+	}
+}
+
+// Close stops accepting events and waits for the queued ones to be delivered
+func (q *queue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.ch)
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		<-q.done
+
+		return ctx.Err()
+	}
+}
+
+func (q *queue) run() {
+	defer close(q.done)
+
+	for e := range q.ch {
+		// shutdown timed out, don't bother trying
+		if q.ctx.Err() != nil {
+			metrics.Events.WithLabelValues(q.name, string(e.Type), "dropped").Inc()
+			continue
+		}
+
+		err := q.send(q.ctx, e)
+		if err != nil {
+			metrics.Events.WithLabelValues(q.name, string(e.Type), "failed").Inc()
+			slog.Error("failed to deliver event", "sink", q.name, "type", e.Type, "id", e.ID, "error", err)
+			continue
+		}
+
+		metrics.Events.WithLabelValues(q.name, string(e.Type), "sent").Inc()
+	}
+}
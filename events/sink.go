@@ -0,0 +1,170 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package events
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/flatgrassdotnet/cloudbox/utils"
+)
+
+var sinkTypes = []string{"discord", "webhook", "slack", "matrix", "file"}
+
+// SinkConfig describes an outbound sink, written as whitespace separated key=value pairs:
+//
+//	type=webhook url=https://example.com/hook?tags=a,b secret=hunter2 events=login+map_load
+//
+// whitespace can't appear in a url, so values may contain anything else including commas
+// type is one of discord, webhook, slack, matrix or file, file sinks take a path instead of a url
+// events is a + separated filter, every event is sent when it's left out
+// name labels the sink in logs and metrics, queue limits how many events may wait for delivery
+type SinkConfig struct {
+	Type   string
+	Name   string
+	URL    string
+	Secret string // webhook only, signs the body
+	Path   string
+	Events []Type
+	Queue  int
+}
+
+func ParseSinkConfig(s string) (SinkConfig, error) {
+	c := SinkConfig{Queue: 1000}
+
+	for _, pair := range strings.Fields(s) {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return SinkConfig{}, fmt.Errorf("expected key=value, got %q", pair)
+		}
+
+		switch key {
+		case "type":
+			c.Type = value
+		case "name":
+			c.Name = value
+		case "url":
+			c.URL = value
+		case "secret":
+			c.Secret = value
+		case "path":
+			c.Path = value
+		case "events":
+			for _, name := range strings.Split(value, "+") {
+				t, err := ParseType(name)
+				if err != nil {
+					return SinkConfig{}, err
+				}
+
+				c.Events = append(c.Events, t)
+			}
+		case "queue":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return SinkConfig{}, fmt.Errorf("queue must be a positive number, got %q", value)
+			}
+
+			c.Queue = n
+		default:
+			return SinkConfig{}, fmt.Errorf("unknown sink option: %s", key)
+		}
+	}
+
+	if !slices.Contains(sinkTypes, c.Type) {
+		return SinkConfig{}, fmt.Errorf("sink type must be one of %s, got %q", strings.Join(sinkTypes, ", "), c.Type)
+	}
+
+	if c.Name == "" {
+		c.Name = c.Type
+	}
+
+	if c.Type == "file" {
+		if c.Path == "" {
+			return SinkConfig{}, errors.New("file sinks require a path")
+		}
+	} else {
+		u, err := url.Parse(c.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return SinkConfig{}, fmt.Errorf("%s sinks require an http or https url", c.Type)
+		}
+	}
+
+	if c.Secret != "" && c.Type != "webhook" {
+		return SinkConfig{}, errors.New("only webhook sinks support a secret")
+	}
+
+	return c, nil
+}
+
+func (c SinkConfig) String() string {
+	return c.format(false)
+}
+
+// Redacted formats the config like String but hides the url and secret
+func (c SinkConfig) Redacted() string {
+	return c.format(true)
+}
+
+func (c SinkConfig) format(redact bool) string {
+	var pairs []string
+	add := func(key string, value string, secret bool) {
+		if value == "" {
+			return
+		}
+
+		if secret && redact {
+			value = "REDACTED"
+		}
+
+		pairs = append(pairs, key+"="+value)
+	}
+
+	var events []string
+	for _, t := range c.Events {
+		events = append(events, string(t))
+	}
+
+	add("type", c.Type, false)
+	add("name", c.Name, false)
+	add("url", c.URL, true)
+	add("secret", c.Secret, true)
+	add("path", c.Path, false)
+	add("events", strings.Join(events, "+"), false)
+	add("queue", strconv.Itoa(c.Queue), false)
+
+	return strings.Join(pairs, " ")
+}
+
+// NewSink creates the sink c describes, subscribe it using c.Events
+func NewSink(c SinkConfig) (Sink, error) {
+	switch c.Type {
+	case "discord":
+		return NewDiscordSink(utils.NewDiscordQueue(c.Name, c.URL, c.Queue)), nil
+	case "webhook", "slack", "matrix":
+		return newHTTPSink(c), nil
+	case "file":
+		return newFileSink(c)
+	}
+
+	return nil, fmt.Errorf("unknown sink type: %s", c.Type)
+}
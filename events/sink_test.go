@@ -0,0 +1,135 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSinkConfig(t *testing.T) {
+	tests := []struct {
+		spec string
+		want SinkConfig
+	}{
+		{
+			spec: "type=webhook url=https://example.com/hook?tags=a,b&x=1 secret=hun,ter=2 events=login+map_load",
+			want: SinkConfig{Type: "webhook", Name: "webhook", URL: "https://example.com/hook?tags=a,b&x=1", Secret: "hun,ter=2", Events: []Type{TypeLogin, TypeMapLoad}, Queue: 1000},
+		},
+		{
+			spec: "  type=file\tname=audit   path=/var/log/cloudbox,events.jsonl queue=10 ",
+			want: SinkConfig{Type: "file", Name: "audit", Path: "/var/log/cloudbox,events.jsonl", Queue: 10},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSinkConfig(tt.spec)
+		if err != nil {
+			t.Fatalf("failed to parse %q: %s", tt.spec, err)
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("parsing %q got %#v, want %#v", tt.spec, got, tt.want)
+		}
+
+		// String must parse back to the same config
+		again, err := ParseSinkConfig(got.String())
+		if err != nil || !reflect.DeepEqual(again, got) {
+			t.Fatalf("round trip of %q got %#v, %v", got.String(), again, err)
+		}
+	}
+
+	for _, spec := range []string{
+		"type=webhook",
+		"type=ftp url=https://example.com",
+		"type=slack url=https://example.com secret=x",
+		"type=file",
+		"type=file path=x events=nope",
+		"type=file path=x queue=0",
+	} {
+		_, err := ParseSinkConfig(spec)
+		if err == nil {
+			t.Fatalf("parsed invalid spec %q", spec)
+		}
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	c, err := ParseSinkConfig("type=file path=" + path)
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+
+	sink, err := NewSink(c)
+	if err != nil {
+		t.Fatalf("failed to create sink: %s", err)
+	}
+
+	var bus Bus
+	bus.Subscribe(sink)
+
+	for i := range 100 {
+		bus.Publish(MapLoad{SteamID: "1", Map: strings.Repeat("x", i)})
+	}
+
+	// everything queued is written before Close returns
+	err = bus.Close(context.Background())
+	if err != nil {
+		t.Fatalf("failed to close: %s", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open: %s", err)
+	}
+
+	defer f.Close()
+
+	var n int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e struct {
+			Type Type
+			Data MapLoad
+		}
+
+		err = json.Unmarshal(scanner.Bytes(), &e)
+		if err != nil {
+			t.Fatalf("line %d isn't json: %s", n, err)
+		}
+
+		if e.Type != TypeMapLoad || e.Data.Map != strings.Repeat("x", n) {
+			t.Fatalf("line %d is %#v", n, e)
+		}
+
+		n++
+	}
+
+	if n != 100 {
+		t.Fatalf("got %d lines, want 100", n)
+	}
+}
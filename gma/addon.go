@@ -0,0 +1,56 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gma
+
+import (
+	"fmt"
+	"slices"
+)
+
+// the addon.json values gmad accepts
+var (
+	Types = []string{"gamemode", "map", "weapon", "vehicle", "npc", "entity", "tool", "effects", "model", "servercontent"}
+	Tags  = []string{"fun", "roleplay", "scenic", "movie", "realism", "cartoon", "water", "comic", "build"}
+)
+
+// gmad refuses more tags than this
+const MaxTags = 2
+
+// Validate checks m against the addon.json rules gmad enforces
+func (m Metadata) Validate() error {
+	if !slices.Contains(Types, m.Type) {
+		return fmt.Errorf("gma: invalid addon type %q", m.Type)
+	}
+
+	if len(m.Tags) > MaxTags {
+		return fmt.Errorf("gma: too many tags, %d of %d", len(m.Tags), MaxTags)
+	}
+
+	for i, tag := range m.Tags {
+		if !slices.Contains(Tags, tag) {
+			return fmt.Errorf("gma: invalid tag %q", tag)
+		}
+
+		if slices.Contains(m.Tags[:i], tag) {
+			return fmt.Errorf("gma: duplicate tag %q", tag)
+		}
+	}
+
+	return nil
+}
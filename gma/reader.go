@@ -0,0 +1,387 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package gma reads garry's mod addon (GMAD) archives
+package gma
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	Magic   = "GMAD"
+	Version = 3
+)
+
+const (
+	maxStringLength = 1 << 20
+	maxFiles        = 1 << 16
+)
+
+var (
+	ErrFormat   = errors.New("gma: not a valid gma file")
+	ErrChecksum = errors.New("gma: checksum error")
+)
+
+type Header struct {
+	Version         uint8
+	SteamID         uint64 // unused by gmad, usually 0
+	Timestamp       time.Time
+	RequiredContent []string // unused by gmad
+	Name            string
+	Description     string // json, see Metadata
+	Author          string // unused by gmad
+	AddonVersion    int32  // unused by gmad
+}
+
+// Metadata is the json gmad stores in the description field
+type Metadata struct {
+	Description string   `json:"description"`
+	Type        string   `json:"type"`
+	Tags        []string `json:"tags"`
+}
+
+// Metadata parses the description field, old addons may have plain text there instead
+func (h Header) Metadata() (Metadata, error) {
+	var m Metadata
+
+	err := json.Unmarshal([]byte(h.Description), &m)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("gma: invalid metadata: %s", err)
+	}
+
+	return m, nil
+}
+
+type File struct {
+	Name   string
+	Size   int64
+	CRC    uint32 // crc32 (ieee) of the contents, 0 if the writer didn't compute it
+	Offset int64  // from the start of the file contents section
+}
+
+// Reader reads an addon front to back
+// call Next to move to each file in turn, then Read its contents
+type Reader struct {
+	Header
+	Files []File
+
+	r *crcReader
+
+	next      int
+	cur       *File
+	remaining int64
+	crc       hash.Hash32
+	checked   bool
+	done      bool
+}
+
+// NewReader reads the header and file table from r
+func NewReader(r io.Reader) (*Reader, error) {
+	gr := &Reader{
+		r:   &crcReader{r: bufio.NewReader(r), crc: crc32.NewIEEE()},
+		crc: crc32.NewIEEE(),
+	}
+
+	err := gr.readHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	err = gr.readFileTable()
+	if err != nil {
+		return nil, err
+	}
+
+	return gr, nil
+}
+
+func (r *Reader) readHeader() error {
+	magic := make([]byte, len(Magic))
+
+	_, err := io.ReadFull(r.r, magic)
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+
+	if string(magic) != Magic {
+		return ErrFormat
+	}
+
+	r.Version, err = r.r.ReadByte()
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+
+	if r.Version < 1 || r.Version > Version {
+		return fmt.Errorf("gma: unsupported version %d", r.Version)
+	}
+
+	var timestamp uint64
+	err = r.read(&r.SteamID, &timestamp)
+	if err != nil {
+		return err
+	}
+
+	r.Timestamp = time.Unix(int64(timestamp), 0).UTC()
+
+	// list of strings ended by an empty one
+	if r.Version > 1 {
+		for {
+			s, err := r.r.readString()
+			if err != nil {
+				return err
+			}
+
+			if s == "" {
+				break
+			}
+
+			r.RequiredContent = append(r.RequiredContent, s)
+		}
+	}
+
+	for _, s := range []*string{&r.Name, &r.Description, &r.Author} {
+		*s, err = r.r.readString()
+		if err != nil {
+			return err
+		}
+	}
+
+	return r.read(&r.AddonVersion)
+}
+
+func (r *Reader) readFileTable() error {
+	var offset int64
+	seen := make(map[string]bool)
+
+	for {
+		var number uint32
+		err := r.read(&number)
+		if err != nil {
+			return err
+		}
+
+		// file numbers start at 1, 0 ends the list
+		if number == 0 {
+			return nil
+		}
+
+		if len(r.Files) == maxFiles {
+			return fmt.Errorf("gma: more than %d files", maxFiles)
+		}
+
+		var f File
+		f.Name, err = r.r.readString()
+		if err != nil {
+			return err
+		}
+
+		err = r.read(&f.Size, &f.CRC)
+		if err != nil {
+			return err
+		}
+
+		err = validateName(f.Name)
+		if err != nil {
+			return err
+		}
+
+		if seen[f.Name] {
+			return fmt.Errorf("gma: duplicate file %q", f.Name)
+		}
+
+		seen[f.Name] = true
+
+		if f.Size < 0 {
+			return fmt.Errorf("gma: negative size for %q", f.Name)
+		}
+
+		f.Offset = offset
+		offset += f.Size
+
+		r.Files = append(r.Files, f)
+	}
+}
+
+// validateName rejects paths that would escape the directory an addon is extracted to
+func validateName(name string) error {
+	if name == "" || strings.Contains(name, "\\") || path.IsAbs(name) || path.Clean(name) != name || name == ".." || strings.HasPrefix(name, "../") {
+		return fmt.Errorf("gma: invalid file name %q", name)
+	}
+
+	return nil
+}
+
+// Next moves to the next file, skipping whatever is left of the current one
+// after the last file it verifies the addon checksum and returns io.EOF
+func (r *Reader) Next() (*File, error) {
+	if r.cur != nil {
+		_, err := io.Copy(io.Discard, r)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if r.next == len(r.Files) {
+		r.cur = nil
+
+		if !r.done {
+			r.done = true
+
+			err := r.verify()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, io.EOF
+	}
+
+	r.cur = &r.Files[r.next]
+	r.next++
+	r.remaining = r.cur.Size
+	r.crc.Reset()
+	r.checked = false
+
+	return r.cur, nil
+}
+
+// Read reads the contents of the current file
+// it returns ErrChecksum instead of io.EOF if the contents don't match the file's crc
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.cur == nil {
+		return 0, io.EOF
+	}
+
+	if r.remaining == 0 {
+		if !r.checked {
+			r.checked = true
+
+			if r.cur.CRC != 0 && r.crc.Sum32() != r.cur.CRC {
+				return 0, ErrChecksum
+			}
+		}
+
+		return 0, io.EOF
+	}
+
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+
+	n, err := r.r.Read(p)
+	r.crc.Write(p[:n])
+	r.remaining -= int64(n)
+
+	if err == io.EOF && r.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	} else if err == io.EOF {
+		err = nil
+	}
+
+	return n, err
+}
+
+// verify compares the trailing addon crc against everything read before it, 0 is not checked
+func (r *Reader) verify() error {
+	sum := r.r.crc.Sum32()
+
+	var stored uint32
+	err := binary.Read(r.r.r, binary.LittleEndian, &stored)
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+
+	if stored != 0 && stored != sum {
+		return ErrChecksum
+	}
+
+	return nil
+}
+
+func (r *Reader) read(values ...any) error {
+	for _, v := range values {
+		err := binary.Read(r.r, binary.LittleEndian, v)
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+	}
+
+	return nil
+}
+
+// crcReader keeps a running crc of everything read through it
+type crcReader struct {
+	r   *bufio.Reader
+	crc hash.Hash32
+}
+
+func (r *crcReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.crc.Write(p[:n])
+
+	return n, err
+}
+
+func (r *crcReader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.crc.Write([]byte{b})
+	}
+
+	return b, err
+}
+
+func (r *crcReader) readString() (string, error) {
+	var sb strings.Builder
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", unexpectedEOF(err)
+		}
+
+		if b == 0 {
+			return sb.String(), nil
+		}
+
+		if sb.Len() == maxStringLength {
+			return "", errors.New("gma: string too long")
+		}
+
+		sb.WriteByte(b)
+	}
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
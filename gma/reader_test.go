@@ -0,0 +1,302 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gma
+
+import (
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testFile struct {
+	name     string
+	contents string
+}
+
+var testHeader = Header{
+	Version:         Version,
+	SteamID:         76561197960287930,
+	Timestamp:       time.Unix(1700000000, 0).UTC(),
+	RequiredContent: []string{"cstrike"},
+	Name:            "Test Addon",
+	Description:     `{"description":"a test","type":"tool","tags":["fun","build"]}`,
+	Author:          "tester",
+	AddonVersion:    1,
+}
+
+var testFiles = []testFile{
+	{"lua/autorun/test.lua", "print(\"hello\")\n"},
+	{"materials/empty.vmt", ""},
+	{"sound/test.wav", strings.Repeat("\x00\x01\x02\x03", 4096)},
+}
+
+// writeAddon builds an addon in memory, files get their real crc unless nocrc is set
+func writeAddon(t *testing.T, h Header, files []testFile, nocrc bool) []byte {
+	t.Helper()
+
+	var table []File
+	for _, f := range files {
+		file := File{Name: f.name, Size: int64(len(f.contents))}
+		if !nocrc {
+			file.CRC = crc32.ChecksumIEEE([]byte(f.contents))
+		}
+
+		table = append(table, file)
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	err := w.WriteHeader(h, table)
+	if err != nil {
+		t.Fatalf("failed to write header: %s", err)
+	}
+
+	for _, f := range files {
+		err = w.WriteFile(strings.NewReader(f.contents))
+		if err != nil {
+			t.Fatalf("failed to write %s: %s", f.name, err)
+		}
+	}
+
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("failed to close: %s", err)
+	}
+
+	return buf.Bytes()
+}
+
+// readAddon reads every file, returning the first error
+func readAddon(data []byte) (*Reader, map[string]string, error) {
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	contents := make(map[string]string)
+	for {
+		f, err := r.Next()
+		if err == io.EOF {
+			return r, contents, nil
+		}
+		if err != nil {
+			return r, contents, err
+		}
+
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return r, contents, err
+		}
+
+		contents[f.Name] = string(b)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	data := writeAddon(t, testHeader, testFiles, false)
+
+	r, contents, err := readAddon(data)
+	if err != nil {
+		t.Fatalf("failed to read: %s", err)
+	}
+
+	if !reflect.DeepEqual(r.Header, testHeader) {
+		t.Fatalf("got header %#v, want %#v", r.Header, testHeader)
+	}
+
+	var offset int64
+	for i, f := range testFiles {
+		want := File{Name: f.name, Size: int64(len(f.contents)), CRC: crc32.ChecksumIEEE([]byte(f.contents)), Offset: offset}
+		if r.Files[i] != want {
+			t.Fatalf("got file %#v, want %#v", r.Files[i], want)
+		}
+
+		if contents[f.name] != f.contents {
+			t.Fatalf("got %d bytes for %s, want %d", len(contents[f.name]), f.name, len(f.contents))
+		}
+
+		offset += want.Size
+	}
+
+	m, err := r.Metadata()
+	if err != nil {
+		t.Fatalf("failed to parse metadata: %s", err)
+	}
+
+	if m.Type != "tool" || !reflect.DeepEqual(m.Tags, []string{"fun", "build"}) {
+		t.Fatalf("got metadata %#v", m)
+	}
+}
+
+func TestSkipFiles(t *testing.T) {
+	data := writeAddon(t, testHeader, testFiles, false)
+
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to read: %s", err)
+	}
+
+	// Next skips whatever isn't read and still verifies the addon crc
+	for {
+		_, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to skip: %s", err)
+		}
+	}
+}
+
+// contentsOffset finds where the contents of the file at index i start
+func contentsOffset(t *testing.T, data []byte, i int) int {
+	t.Helper()
+
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to read: %s", err)
+	}
+
+	var size int64
+	for _, f := range r.Files {
+		size += f.Size
+	}
+
+	// contents are followed only by the 4 byte addon crc
+	return len(data) - 4 - int(size) + int(r.Files[i].Offset)
+}
+
+func TestFileChecksum(t *testing.T) {
+	data := writeAddon(t, testHeader, testFiles, false)
+	data[contentsOffset(t, data, 0)] ^= 0xff
+
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to read: %s", err)
+	}
+
+	_, err = r.Next()
+	if err != nil {
+		t.Fatalf("failed to move to the first file: %s", err)
+	}
+
+	_, err = io.ReadAll(r)
+	if !errors.Is(err, ErrChecksum) {
+		t.Fatalf("got error %v, want %v", err, ErrChecksum)
+	}
+}
+
+func TestAddonChecksum(t *testing.T) {
+	// without file crcs only the addon crc can catch it
+	data := writeAddon(t, testHeader, testFiles, true)
+	data[contentsOffset(t, data, 2)] ^= 0xff
+
+	_, _, err := readAddon(data)
+	if !errors.Is(err, ErrChecksum) {
+		t.Fatalf("got error %v, want %v", err, ErrChecksum)
+	}
+
+	// a stored crc of 0 isn't checked
+	data = writeAddon(t, testHeader, testFiles, true)
+	data[contentsOffset(t, data, 2)] ^= 0xff
+	copy(data[len(data)-4:], []byte{0, 0, 0, 0})
+
+	_, _, err = readAddon(data)
+	if err != nil {
+		t.Fatalf("got error %v with no addon crc", err)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	valid := writeAddon(t, testHeader, testFiles, false)
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"bad magic", append([]byte("GMAX"), valid[4:]...), ErrFormat},
+		{"truncated header", valid[:10], io.ErrUnexpectedEOF},
+		{"truncated contents", valid[:len(valid)-10], io.ErrUnexpectedEOF},
+		{"missing crc", valid[:len(valid)-4], io.ErrUnexpectedEOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := readAddon(tt.data)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got error %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvalidNames(t *testing.T) {
+	for _, name := range []string{"", "/etc/passwd", "../escape.lua", "..", "lua/../../x.lua", "lua//x.lua", `lua\x.lua`} {
+		t.Run(name, func(t *testing.T) {
+			w := NewWriter(io.Discard)
+
+			err := w.WriteHeader(testHeader, []File{{Name: name}})
+			if err == nil || !strings.Contains(err.Error(), "invalid file name") {
+				t.Fatalf("got error %v writing %q", err, name)
+			}
+
+			// bypass the writer's own check to make sure the reader rejects it too
+			data := writeAddon(t, testHeader, []testFile{{"lua/placeholder.lua", ""}}, false)
+			data = bytes.Replace(data, []byte("lua/placeholder.lua\x00"), []byte(name+"\x00"), 1)
+
+			_, err = NewReader(bytes.NewReader(data))
+			if err == nil || !strings.Contains(err.Error(), "invalid file name") {
+				t.Fatalf("got error %v reading %q", err, name)
+			}
+		})
+	}
+}
+
+func TestWriterErrors(t *testing.T) {
+	w := NewWriter(io.Discard)
+
+	err := w.WriteHeader(testHeader, []File{{Name: "a.lua", Size: 10}})
+	if err != nil {
+		t.Fatalf("failed to write header: %s", err)
+	}
+
+	err = w.WriteFile(strings.NewReader("short"))
+	if err == nil || !strings.Contains(err.Error(), "5 bytes short") {
+		t.Fatalf("got error %v for a short file", err)
+	}
+
+	w = NewWriter(io.Discard)
+
+	err = w.WriteHeader(testHeader, []File{{Name: "a.lua", Size: 1}})
+	if err != nil {
+		t.Fatalf("failed to write header: %s", err)
+	}
+
+	err = w.Close()
+	if err == nil || !strings.Contains(err.Error(), "0 of 1 files written") {
+		t.Fatalf("got error %v closing early", err)
+	}
+}
@@ -0,0 +1,201 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gma
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// DefaultWhitelist is the file whitelist gmad uses when creating addons
+var DefaultWhitelist = MustCompileWhitelist(
+	"lua/*.lua",
+	"scenes/*.vcd",
+	"particles/*.pcf",
+	"resource/fonts/*.ttf",
+	"scripts/vehicles/*.txt",
+	"resource/localization/*/*.properties",
+	"maps/*.bsp",
+	"maps/*.lmp",
+	"maps/*.nav",
+	"maps/*.ain",
+	"maps/thumb/*.png",
+	"sound/*.wav",
+	"sound/*.mp3",
+	"sound/*.ogg",
+	"materials/*.vmt",
+	"materials/*.vtf",
+	"materials/*.png",
+	"materials/*.jpg",
+	"materials/*.jpeg",
+	"materials/colorcorrection/*.raw",
+	"models/*.mdl",
+	"models/*.phy",
+	"models/*.ani",
+	"models/*.vvd",
+
+	"models/*.vtx",
+	"!models/*.sw.vtx", // these variations are unused by the game
+	"!models/*.360.vtx",
+	"!models/*.xbox.vtx",
+
+	"gamemodes/*/*.txt",
+	"!gamemodes/*/*/*.txt", // only in the root gamemode folder please!
+	"gamemodes/*/*.fgd",
+	"!gamemodes/*/*/*.fgd",
+
+	"gamemodes/*/logo.png",
+	"gamemodes/*/icon24.png",
+	"gamemodes/*/gamemode/*.lua",
+	"gamemodes/*/entities/effects/*.lua",
+	"gamemodes/*/entities/weapons/*.lua",
+	"gamemodes/*/entities/entities/*.lua",
+	"gamemodes/*/backgrounds/*.png",
+	"gamemodes/*/backgrounds/*.jpg",
+	"gamemodes/*/backgrounds/*.jpeg",
+	"gamemodes/*/content/models/*.mdl",
+	"gamemodes/*/content/models/*.phy",
+	"gamemodes/*/content/models/*.ani",
+	"gamemodes/*/content/models/*.vvd",
+
+	"gamemodes/*/content/models/*.vtx",
+	"!gamemodes/*/content/models/*.sw.vtx",
+	"!gamemodes/*/content/models/*.360.vtx",
+	"!gamemodes/*/content/models/*.xbox.vtx",
+
+	"gamemodes/*/content/materials/*.vmt",
+	"gamemodes/*/content/materials/*.vtf",
+	"gamemodes/*/content/materials/*.png",
+	"gamemodes/*/content/materials/*.jpg",
+	"gamemodes/*/content/materials/*.jpeg",
+	"gamemodes/*/content/materials/colorcorrection/*.raw",
+	"gamemodes/*/content/scenes/*.vcd",
+	"gamemodes/*/content/particles/*.pcf",
+	"gamemodes/*/content/resource/fonts/*.ttf",
+	"gamemodes/*/content/scripts/vehicles/*.txt",
+	"gamemodes/*/content/resource/localization/*/*.properties",
+	"gamemodes/*/content/maps/*.bsp",
+	"gamemodes/*/content/maps/*.nav",
+	"gamemodes/*/content/maps/*.ain",
+	"gamemodes/*/content/maps/thumb/*.png",
+	"gamemodes/*/content/sound/*.wav",
+	"gamemodes/*/content/sound/*.mp3",
+	"gamemodes/*/content/sound/*.ogg",
+
+	// static version of the data/ folder
+	// (because you wouldn't be able to modify these)
+	// we only allow filetypes here that are not already allowed above
+	"data_static/*.txt",
+	"data_static/*.dat",
+	"data_static/*.json",
+	"data_static/*.xml",
+	"data_static/*.csv",
+)
+
+// Whitelist decides which files may go in an addon, matching like gmad does
+// a name is allowed if any allow pattern matches and no deny pattern ("!" prefix) does, regardless of order
+// patterns are globs where * matches any run of characters including slashes and ? matches one character
+type Whitelist struct {
+	allow []rule
+	deny  []rule
+}
+
+type rule struct {
+	pattern string // as given, including "!"
+	re      *regexp.Regexp
+}
+
+// CompileWhitelist compiles patterns once so matching doesn't have to
+func CompileWhitelist(patterns ...string) (*Whitelist, error) {
+	w := new(Whitelist)
+	for _, p := range patterns {
+		glob, deny := strings.CutPrefix(p, "!")
+		if glob == "" {
+			return nil, errors.New("gma: empty whitelist pattern")
+		}
+
+		var expr strings.Builder
+		expr.WriteString("^")
+		for _, c := range glob {
+			switch c {
+			case '*':
+				expr.WriteString("(?s:.*)")
+			case '?':
+				expr.WriteString("(?s:.)")
+			default:
+				expr.WriteString(regexp.QuoteMeta(string(c)))
+			}
+		}
+		expr.WriteString("$")
+
+		re, err := regexp.Compile(expr.String())
+		if err != nil {
+			return nil, err
+		}
+
+		if deny {
+			w.deny = append(w.deny, rule{p, re})
+		} else {
+			w.allow = append(w.allow, rule{p, re})
+		}
+	}
+
+	return w, nil
+}
+
+// MustCompileWhitelist is like CompileWhitelist but panics on invalid patterns
+func MustCompileWhitelist(patterns ...string) *Whitelist {
+	w, err := CompileWhitelist(patterns...)
+	if err != nil {
+		panic(err)
+	}
+
+	return w
+}
+
+// Match reports whether name is allowed and the pattern that decided it
+// that is the first allow pattern that matched, or the first deny pattern that overruled it
+// rule is empty if no allow pattern matched
+// names are lowercased first like gmad does, patterns should be lowercase
+func (w *Whitelist) Match(name string) (rule string, ok bool) {
+	name = strings.ToLower(name)
+
+	for _, a := range w.allow {
+		if !a.re.MatchString(name) {
+			continue
+		}
+
+		for _, d := range w.deny {
+			if d.re.MatchString(name) {
+				return d.pattern, false
+			}
+		}
+
+		return a.pattern, true
+	}
+
+	return "", false
+}
+
+// Allowed reports whether name may go in an addon
+func (w *Whitelist) Allowed(name string) bool {
+	_, ok := w.Match(name)
+	return ok
+}
@@ -0,0 +1,110 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gma
+
+import "testing"
+
+func TestDefaultWhitelist(t *testing.T) {
+	tests := []struct {
+		name string
+		ok   bool
+		rule string
+	}{
+		// plain allows
+		{"lua/autorun/test.lua", true, "lua/*.lua"},
+		{"maps/gm_test.bsp", true, "maps/*.bsp"},
+		{"materials/models/test.vtf", true, "materials/*.vtf"},
+		{"models/test.dx90.vtx", true, "models/*.vtx"},
+		{"data_static/config.json", true, "data_static/*.json"},
+		{"resource/localization/en/test.properties", true, "resource/localization/*/*.properties"},
+
+		// not on the list at all
+		{"lua/test.luac", false, ""},
+		{"test.lua", false, ""},
+		{"addon.json", false, ""},
+		{"data/test.txt", false, ""},
+		{"maps/gm_test.bsp.bak", false, ""},
+		{"sound/test.flac", false, ""},
+
+		// case folding, gmad lowercases names before checking
+		{"LUA/Autorun/Test.LUA", true, "lua/*.lua"},
+		{"Maps/GM_Test.BSP", true, "maps/*.bsp"},
+		{"MODELS/TEST.SW.VTX", false, "!models/*.sw.vtx"},
+
+		// * not crossing / where gmad's deny patterns stop it
+		{"gamemodes/test/test.txt", true, "gamemodes/*/*.txt"},
+		{"gamemodes/test/sub/test.txt", false, "!gamemodes/*/*/*.txt"},
+		{"gamemodes/test/test.fgd", true, "gamemodes/*/*.fgd"},
+		{"gamemodes/test/sub/test.fgd", false, "!gamemodes/*/*/*.fgd"},
+		{"gamemodes/test/content/models/sub/test.vtx", true, "gamemodes/*/content/models/*.vtx"},
+
+		// deny overrides allow, wherever it appears in the list
+		{"models/test.sw.vtx", false, "!models/*.sw.vtx"},
+		{"models/test.360.vtx", false, "!models/*.360.vtx"},
+		{"models/props/test.xbox.vtx", false, "!models/*.xbox.vtx"},
+		{"gamemodes/test/content/models/test.360.vtx", false, "!gamemodes/*/content/models/*.360.vtx"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, ok := DefaultWhitelist.Match(tt.name)
+			if ok != tt.ok || rule != tt.rule {
+				t.Fatalf("got (%q, %t), want (%q, %t)", rule, ok, tt.rule, tt.ok)
+			}
+
+			if DefaultWhitelist.Allowed(tt.name) != tt.ok {
+				t.Fatalf("Allowed disagrees with Match")
+			}
+		})
+	}
+}
+
+func TestWhitelistPatterns(t *testing.T) {
+	w := MustCompileWhitelist("!*.bak", "a/?.txt", "b/*", "c/[x].txt", "c/*.bak")
+
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"a/1.txt", true},
+		{"a/12.txt", false},
+		{"a/.txt", false},
+		{"b/", true},
+		{"b/anything/at/all", true},
+		// regexp characters are literal
+		{"c/[x].txt", true},
+		{"c/x.txt", false},
+		// a deny listed before the allow still wins
+		{"c/test.bak", false},
+		{"b/test.bak", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w.Allowed(tt.name) != tt.ok {
+				t.Fatalf("got %t, want %t", !tt.ok, tt.ok)
+			}
+		})
+	}
+
+	_, err := CompileWhitelist("lua/*.lua", "!")
+	if err == nil {
+		t.Fatalf("compiled an empty pattern")
+	}
+}
@@ -0,0 +1,169 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gma
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+)
+
+// Writer writes an addon without holding its contents in memory
+// the header and file table go first, then each file's contents in the same order
+type Writer struct {
+	dst io.Writer
+	w   io.Writer // dst and crc
+	crc hash.Hash32
+
+	files []File
+	next  int
+}
+
+func NewWriter(w io.Writer) *Writer {
+	crc := crc32.NewIEEE()
+
+	return &Writer{dst: w, w: io.MultiWriter(w, crc), crc: crc}
+}
+
+// WriteHeader writes h and the file table as version 3, Version and Offset are ignored
+func (w *Writer) WriteHeader(h Header, files []File) error {
+	if w.files != nil {
+		return errors.New("gma: header already written")
+	}
+
+	for _, f := range files {
+		err := validateName(f.Name)
+		if err != nil {
+			return err
+		}
+
+		if f.Size < 0 {
+			return fmt.Errorf("gma: negative size for %q", f.Name)
+		}
+	}
+
+	// gmad writes 0 when it doesn't know
+	var timestamp uint64
+	if !h.Timestamp.IsZero() {
+		timestamp = uint64(h.Timestamp.Unix())
+	}
+
+	err := w.write([]byte(Magic), uint8(Version), h.SteamID, timestamp)
+	if err != nil {
+		return err
+	}
+
+	// required content, ended by an empty string
+	for _, s := range append(h.RequiredContent, "") {
+		err = w.writeString(s)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, s := range []string{h.Name, h.Description, h.Author} {
+		err = w.writeString(s)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = w.write(h.AddonVersion)
+	if err != nil {
+		return err
+	}
+
+	for i, f := range files {
+		err = w.write(uint32(i + 1))
+		if err != nil {
+			return err
+		}
+
+		err = w.writeString(f.Name)
+		if err != nil {
+			return err
+		}
+
+		err = w.write(f.Size, f.CRC)
+		if err != nil {
+			return err
+		}
+	}
+
+	// end of file list marker
+	err = w.write(uint32(0))
+	if err != nil {
+		return err
+	}
+
+	w.files = append([]File{}, files...)
+
+	return nil
+}
+
+// WriteFile copies the contents of the next file from r, which must hold at least its size
+func (w *Writer) WriteFile(r io.Reader) error {
+	if w.next == len(w.files) {
+		return errors.New("gma: no more files in the file table")
+	}
+
+	f := w.files[w.next]
+	w.next++
+
+	n, err := io.CopyN(w.w, r, f.Size)
+	if err == io.EOF {
+		return fmt.Errorf("gma: %s is %d bytes short", f.Name, f.Size-n)
+	}
+
+	return err
+}
+
+// Close writes the addon crc, it doesn't close the underlying writer
+func (w *Writer) Close() error {
+	if w.next != len(w.files) {
+		return fmt.Errorf("gma: %d of %d files written", w.next, len(w.files))
+	}
+
+	return binary.Write(w.dst, binary.LittleEndian, w.crc.Sum32())
+}
+
+func (w *Writer) write(values ...any) error {
+	for _, v := range values {
+		err := binary.Write(w.w, binary.LittleEndian, v)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *Writer) writeString(s string) error {
+	if strings.IndexByte(s, 0) != -1 {
+		return fmt.Errorf("gma: %q contains a null byte", s)
+	}
+
+	_, err := io.WriteString(w.w, s+"\x00")
+
+	return err
+}
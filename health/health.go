@@ -0,0 +1,157 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Check is a single readiness check, Func returns nil when the dependency is usable
+type Check struct {
+	Name string
+	Func func(ctx context.Context) error
+}
+
+type Result struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+// OK reports whether every check passed
+func (r Report) OK() bool {
+	return r.Status == "ok"
+}
+
+// Pinger is a database, *sql.DB and db.DB both fit
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+type Handler struct {
+	// DB is pinged by every readiness check as "database", before Checks
+	DB      Pinger
+	Checks  []Check
+	Timeout time.Duration // per readiness request, 0 for none
+
+	// PublicInterval is how long PublicReadyz reuses a result for, 0 runs the checks every time
+	PublicInterval time.Duration
+
+	mu      sync.Mutex
+	checked time.Time
+	ok      bool
+}
+
+// Run runs all checks concurrently
+func (h *Handler) Run(ctx context.Context) Report {
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+
+	checks := h.Checks
+	if h.DB != nil {
+		checks = append([]Check{{Name: "database", Func: h.DB.PingContext}}, checks...)
+	}
+
+	results := make([]Result, len(checks))
+
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := c.Func(ctx)
+			if err != nil {
+				results[i] = Result{Status: "error", Error: err.Error()}
+				return
+			}
+
+			results[i] = Result{Status: "ok"}
+		}()
+	}
+
+	wg.Wait()
+
+	report := Report{Status: "ok", Checks: make(map[string]Result)}
+	for i, c := range checks {
+		report.Checks[c.Name] = results[i]
+		if results[i].Status != "ok" {
+			report.Status = "unavailable"
+		}
+	}
+
+	return report
+}
+
+// Healthz reports that the process is up and serving requests
+func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, Report{Status: "ok"})
+}
+
+// Readyz runs every check and responds 503 if any of them failed
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, h.Run(r.Context()))
+}
+
+// PublicReadyz is Readyz for listeners anyone can reach
+// it only answers ok or fail, dependency errors can leak hostnames and configuration,
+// and the checks run at most once per PublicInterval so requests can't hammer the dependencies
+func (h *Handler) PublicReadyz(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	if h.checked.IsZero() || time.Since(h.checked) >= h.PublicInterval {
+		h.ok = h.Run(r.Context()).OK()
+		h.checked = time.Now()
+	}
+	ok := h.ok
+	h.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, "fail")
+		return
+	}
+
+	fmt.Fprintln(w, "ok")
+}
+
+func writeReport(w http.ResponseWriter, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	if !report.OK() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	json.NewEncoder(w).Encode(report)
+}
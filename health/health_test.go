@@ -0,0 +1,113 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type pinger struct {
+	err   error
+	pings int
+}
+
+func (p *pinger) PingContext(ctx context.Context) error {
+	p.pings++
+	return p.err
+}
+
+func TestReadyz(t *testing.T) {
+	db := &pinger{}
+	h := &Handler{DB: db, Checks: []Check{{Name: "storage", Func: func(ctx context.Context) error { return nil }}}}
+
+	for i, tt := range []struct {
+		err    error
+		status int
+		want   Report
+	}{
+		{nil, http.StatusOK, Report{Status: "ok", Checks: map[string]Result{"database": {Status: "ok"}, "storage": {Status: "ok"}}}},
+		{errors.New("connection refused"), http.StatusServiceUnavailable, Report{Status: "unavailable", Checks: map[string]Result{"database": {Status: "error", Error: "connection refused"}, "storage": {Status: "ok"}}}},
+	} {
+		db.err = tt.err
+
+		rec := httptest.NewRecorder()
+		h.Readyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		if rec.Code != tt.status {
+			t.Fatalf("got status %d, want %d", rec.Code, tt.status)
+		}
+
+		var got Report
+		err := json.NewDecoder(rec.Body).Decode(&got)
+		if err != nil {
+			t.Fatalf("failed to decode report: %s", err)
+		}
+
+		if got.Status != tt.want.Status || len(got.Checks) != len(tt.want.Checks) {
+			t.Fatalf("got %#v, want %#v", got, tt.want)
+		}
+
+		for name, res := range tt.want.Checks {
+			if got.Checks[name] != res {
+				t.Fatalf("got %#v, want %#v", got, tt.want)
+			}
+		}
+
+		// every request pings, nothing is cached
+		if db.pings != i+1 {
+			t.Fatalf("got %d pings after %d requests", db.pings, i+1)
+		}
+	}
+}
+
+func TestPublicReadyz(t *testing.T) {
+	db := &pinger{err: errors.New("dial tcp 10.0.0.5:3306: connection refused")}
+	h := &Handler{DB: db, PublicInterval: time.Hour}
+
+	get := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.PublicReadyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		return rec
+	}
+
+	rec := get()
+	if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != "fail\n" {
+		t.Fatalf("got %d %q", rec.Code, rec.Body.String())
+	}
+
+	// the result is reused, even though the database is back
+	db.err = nil
+	rec = get()
+	if rec.Code != http.StatusServiceUnavailable || db.pings != 1 {
+		t.Fatalf("got %d after %d pings", rec.Code, db.pings)
+	}
+
+	h.PublicInterval = 0
+	rec = get()
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok\n" || db.pings != 2 {
+		t.Fatalf("got %d %q after %d pings", rec.Code, rec.Body.String(), db.pings)
+	}
+}
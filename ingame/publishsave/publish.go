@@ -30,13 +30,13 @@ import (
 
 	"github.com/blezek/tga"
 	"github.com/flatgrassdotnet/cloudbox/common"
-	"github.com/flatgrassdotnet/cloudbox/db"
+	"github.com/flatgrassdotnet/cloudbox/events"
 	"github.com/flatgrassdotnet/cloudbox/utils"
 )
 
 var tp = template.Must(template.New("publish.html").ParseGlob("data/templates/publishsave/*.html"))
 
-func Publish(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) Publish(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
 		utils.WriteError(w, r, fmt.Sprintf("failed to parse form data: %s", err))
@@ -68,33 +68,33 @@ func Publish(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	steamid, err := db.FetchSteamIDFromTicket(ticket)
+	steamid, err := h.Sessions.FetchSteamIDFromTicket(ticket)
 	if err != nil {
 		utils.WriteError(w, r, fmt.Sprintf("failed to fetch steamid from ticket: %s", err))
 		return
 	}
 
-	save, err := db.FetchUpload(id)
+	save, err := h.Uploads.FetchUpload(id)
 	if err != nil {
 		utils.WriteError(w, r, fmt.Sprintf("failed to fetch upload: %s", err))
 		return
 	}
 
-	pkgID, err := db.InsertPackage(common.Package{Type: "savemap", Name: name, Dataname: save.Metadata, Author: steamid, Description: desc, Data: save.Data})
+	pkgID, err := h.Packages.InsertPackage(common.Package{Type: "savemap", Name: name, Dataname: save.Metadata, Author: steamid, Description: desc, Data: save.Data})
 	if err != nil {
 		utils.WriteError(w, r, fmt.Sprintf("failed to insert package: %s", err))
 		return
 	}
 
 	for _, include := range save.Includes {
-		rev, err := db.FetchPackageLatestRevision(include)
+		rev, err := h.Packages.FetchPackageLatestRevision(include)
 		if err != nil {
 			utils.WriteError(w, r, fmt.Sprintf("failed to fetch package latest revision: %s", err))
 			return
 		}
 
 		// save revision should always be 1
-		_, err = db.InsertPackageInclude(pkgID, 1, include, rev)
+		_, err = h.Packages.InsertPackageInclude(pkgID, 1, include, rev)
 		if err != nil {
 			utils.WriteError(w, r, fmt.Sprintf("failed to insert package include: %s", err))
 			return
@@ -102,7 +102,7 @@ func Publish(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// thumbnail
-	thumb, err := db.FetchUpload(sid)
+	thumb, err := h.Uploads.FetchUpload(sid)
 	if err != nil {
 		utils.WriteError(w, r, fmt.Sprintf("failed to fetch upload: %s", err))
 		return
@@ -121,20 +121,20 @@ func Publish(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = db.PutThumbnail(pkgID, buf)
+	err = h.Files.PutThumbnail(pkgID, buf)
 	if err != nil {
 		utils.WriteError(w, r, fmt.Sprintf("failed to upload thumbnail: %s", err))
 		return
 	}
 
 	// clean up
-	err = db.DeleteUpload(id)
+	err = h.Uploads.DeleteUpload(id)
 	if err != nil {
 		utils.WriteError(w, r, fmt.Sprintf("failed to delete upload: %s", err))
 		return
 	}
 
-	err = db.DeleteUpload(sid)
+	err = h.Uploads.DeleteUpload(sid)
 	if err != nil {
 		utils.WriteError(w, r, fmt.Sprintf("failed to delete upload: %s", err))
 		return
@@ -148,29 +148,19 @@ func Publish(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// webhook related
-	s, err := utils.GetPlayerSummaries(steamid)
+	s, err := h.Steam.GetPlayerSummaries(steamid)
 	if err != nil {
 		utils.WriteError(w, r, fmt.Sprintf("failed to get player summary: %s", err))
 		return
 	}
 
-	err = utils.SendDiscordMessage(utils.DiscordSaveWebhookURL, utils.DiscordWebhookRequest{
-		Embeds: []utils.DiscordWebhookEmbed{{
-			Title:       name,
-			Description: desc,
-			Color:       0xB8E3FF,
-			Author: utils.DiscordWebhookEmbedAuthor{
-				Name:    s[0].PersonaName,
-				IconURL: s[0].Avatar,
-			},
-			Image: utils.DiscordWebhookEmbedImage{
-				URL: fmt.Sprintf("https://img.cl0udb0x.com/%d_thumb_128.png", pkgID),
-			},
-		},
-		},
+	h.Events.Publish(events.SavePublished{
+		SteamID:     steamid,
+		Author:      s[0].PersonaName,
+		Avatar:      s[0].Avatar,
+		PackageID:   pkgID,
+		Name:        name,
+		Description: desc,
+		Thumbnail:   utils.ThumbnailURL(pkgID),
 	})
-	if err != nil {
-		utils.WriteError(w, r, fmt.Sprintf("failed to send discord webhook message: %s", err))
-		return
-	}
 }
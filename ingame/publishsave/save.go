@@ -36,7 +36,7 @@ type SaveData struct {
 
 var ts = template.Must(template.New("save.html").ParseGlob("data/templates/publishsave/*.html"))
 
-func Save(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) Save(w http.ResponseWriter, r *http.Request) {
 	sd := SaveData{
 		Map: r.Header.Get("MAP"),
 	}
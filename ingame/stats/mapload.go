@@ -24,12 +24,13 @@ import (
 	"slices"
 	"strconv"
 
-	"github.com/flatgrassdotnet/cloudbox/db"
+	"github.com/flatgrassdotnet/cloudbox/events"
+	"github.com/flatgrassdotnet/cloudbox/metrics"
 	"github.com/flatgrassdotnet/cloudbox/utils"
 )
 
 // mapload records statistics about map usage
-func MapLoad(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) MapLoad(w http.ResponseWriter, r *http.Request) {
 	if !utils.ValidateKey(r.URL.String()) {
 		utils.WriteError(w, r, "invalid key")
 		return
@@ -59,27 +60,21 @@ func MapLoad(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = db.InsertMapLoad(steamid, duration, mapName, platform)
+	err = h.Stats.InsertMapLoad(steamid, duration, mapName, platform)
 	if err != nil {
 		utils.WriteError(w, r, fmt.Sprintf("failed to insert map load: %s", err))
 		return
 	}
 
+	metrics.MapLoads.WithLabelValues(platform).Inc()
+
 	w.WriteHeader(http.StatusOK)
 
 	// webhook related
-	err = utils.SendDiscordMessage(utils.DiscordStatsWebhookURL, utils.DiscordWebhookRequest{
-		Embeds: []utils.DiscordWebhookEmbed{{
-			Title:       "Map Load",
-			Description: mapName,
-			Color:       0x4096EE,
-			Author: utils.DiscordWebhookEmbedAuthor{
-				Name: steamid,
-			},
-		}},
+	h.Events.Publish(events.MapLoad{
+		SteamID:  steamid,
+		Map:      mapName,
+		Duration: duration,
+		Platform: platform,
 	})
-	if err != nil {
-		utils.WriteError(w, r, fmt.Sprintf("failed to send discord webhook message: %s", err))
-		return
-	}
 }
@@ -25,12 +25,13 @@ import (
 	"net/http"
 	"strings"
 
-	"github.com/flatgrassdotnet/cloudbox/db"
+	"github.com/flatgrassdotnet/cloudbox/events"
+	"github.com/flatgrassdotnet/cloudbox/metrics"
 	"github.com/flatgrassdotnet/cloudbox/utils"
 )
 
 // auth logs someone into the toybox api
-func Auth(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) Auth(w http.ResponseWriter, r *http.Request) {
 	// the server returns a 32 character login token on success
 	// any value under 32 characters is treated as an error response
 	// the server responding "chrome" will make an anti piracy message appear
@@ -50,7 +51,7 @@ func Auth(w http.ResponseWriter, r *http.Request) {
 	steamid := utils.UnBinHexString(r.FormValue("u"))
 	vac := utils.UnBinHexString(r.FormValue("vac"))
 
-	user, err := utils.AuthenticateUserTicket(token)
+	user, err := h.Steam.AuthenticateUserTicket(token)
 	if err != nil {
 		utils.WriteError(w, r, fmt.Sprintf("failed to validate steam ticket: %s", err))
 
@@ -67,7 +68,7 @@ func Auth(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// store new profile or get its data
-	s, err := utils.GetPlayerSummaries(steamid)
+	s, err := h.Steam.GetPlayerSummaries(steamid)
 	if err != nil {
 		utils.WriteError(w, r, fmt.Sprintf("failed to get player summary: %s", err))
 		return
@@ -80,27 +81,20 @@ func Auth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = db.InsertLogin(steamid, vac, ticket)
+	err = h.Sessions.InsertLogin(steamid, vac, ticket)
 	if err != nil {
 		utils.WriteError(w, r, fmt.Sprintf("failed to insert login: %s", err))
 		return
 	}
 
+	metrics.Logins.Inc()
+
 	base64.NewEncoder(base64.StdEncoding, w).Write(ticket)
 
 	// webhook related
-	err = utils.SendDiscordMessage(utils.DiscordStatsWebhookURL, utils.DiscordWebhookRequest{
-		Embeds: []utils.DiscordWebhookEmbed{{
-			Title: "Login",
-			Color: 0x4096EE,
-			Author: utils.DiscordWebhookEmbedAuthor{
-				Name:    s[0].PersonaName,
-				IconURL: s[0].Avatar,
-			},
-		}},
+	h.Events.Publish(events.Login{
+		SteamID: steamid,
+		Name:    s[0].PersonaName,
+		Avatar:  s[0].Avatar,
 	})
-	if err != nil {
-		utils.WriteError(w, r, fmt.Sprintf("failed to send discord webhook message: %s", err))
-		return
-	}
 }
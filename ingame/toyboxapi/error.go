@@ -23,12 +23,13 @@ import (
 	"net/http"
 	"slices"
 
-	"github.com/flatgrassdotnet/cloudbox/db"
+	"github.com/flatgrassdotnet/cloudbox/events"
+	"github.com/flatgrassdotnet/cloudbox/metrics"
 	"github.com/flatgrassdotnet/cloudbox/utils"
 )
 
 // error records errors from users
-func Error(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) Error(w http.ResponseWriter, r *http.Request) {
 	if !utils.ValidateKey(r.URL.String()) {
 		utils.WriteError(w, r, "invalid key")
 		return
@@ -61,27 +62,21 @@ func Error(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := db.InsertError(steamid, error, content, realm, platform)
+	err := h.Stats.InsertError(steamid, error, content, realm, platform)
 	if err != nil {
 		utils.WriteError(w, r, fmt.Sprintf("failed to insert error: %s", err))
 		return
 	}
 
+	metrics.LuaErrors.WithLabelValues(realm).Inc()
+
 	w.WriteHeader(http.StatusOK)
 
 	// webhook related
-	err = utils.SendDiscordMessage(utils.DiscordStatsWebhookURL, utils.DiscordWebhookRequest{
-		Embeds: []utils.DiscordWebhookEmbed{{
-			Title:       "Lua Error",
-			Description: error,
-			Color:       0x00007D,
-			Author: utils.DiscordWebhookEmbedAuthor{
-				Name: steamid,
-			},
-		}},
+	h.Events.Publish(events.LuaError{
+		SteamID:  steamid,
+		Error:    error,
+		Realm:    realm,
+		Platform: platform,
 	})
-	if err != nil {
-		utils.WriteError(w, r, fmt.Sprintf("failed to send discord webhook message: %s", err))
-		return
-	}
 }
@@ -25,12 +25,12 @@ import (
 	"net/http"
 	"strconv"
 
-	"github.com/flatgrassdotnet/cloudbox/db"
+	"github.com/flatgrassdotnet/cloudbox/events"
 	"github.com/flatgrassdotnet/cloudbox/utils"
 )
 
 // getpackage returns package metadata
-func GetPackage(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetPackage(w http.ResponseWriter, r *http.Request) {
 	if !utils.ValidateKey(r.URL.String()) {
 		utils.WriteError(w, r, "invalid key")
 		return
@@ -57,7 +57,7 @@ func GetPackage(w http.ResponseWriter, r *http.Request) {
 	// getscript also specifies "type" but scriptids are unique between types
 	// we don't need its value because of this
 
-	pkg, err := db.FetchPackage(id, rev)
+	pkg, err := h.Packages.FetchPackage(id, rev)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			http.Error(w, "package not found", http.StatusNotFound)
@@ -79,21 +79,12 @@ func GetPackage(w http.ResponseWriter, r *http.Request) {
 	w.Write(pkg.Marshal(install))
 
 	// webhook related
-	err = utils.SendDiscordMessage(utils.DiscordStatsWebhookURL, utils.DiscordWebhookRequest{
-		Embeds: []utils.DiscordWebhookEmbed{{
-			Title:       "Package Download",
-			Description: fmt.Sprintf("%s (%dr%d/%s)", pkg.Name, pkg.ID, pkg.Revision, pkg.Type),
-			Color:       0x4096EE,
-			Author: utils.DiscordWebhookEmbedAuthor{
-				Name: steamid,
-			},
-			Image: utils.DiscordWebhookEmbedImage{
-				URL: fmt.Sprintf("https://img.cl0udb0x.com/%d_thumb_128.png", pkg.ID),
-			},
-		}},
+	h.Events.Publish(events.PackageDownload{
+		SteamID:     steamid,
+		PackageID:   pkg.ID,
+		Revision:    pkg.Revision,
+		PackageType: pkg.Type,
+		Name:        pkg.Name,
+		Thumbnail:   utils.ThumbnailURL(pkg.ID),
 	})
-	if err != nil {
-		utils.WriteError(w, r, fmt.Sprintf("failed to send discord webhook message: %s", err))
-		return
-	}
 }
@@ -28,11 +28,10 @@ import (
 	"strconv"
 
 	"github.com/flatgrassdotnet/cloudbox/common"
-	"github.com/flatgrassdotnet/cloudbox/db"
 	"github.com/flatgrassdotnet/cloudbox/utils"
 )
 
-func Upload(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) Upload(w http.ResponseWriter, r *http.Request) {
 	// steamid64
 	steamid, err := strconv.Atoi(r.URL.Query().Get("steamid"))
 	if err != nil {
@@ -81,7 +80,7 @@ func Upload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := db.InsertUpload(steamid, common.Upload{Type: uploadType, Metadata: meta, Includes: includes, Data: body})
+	id, err := h.Uploads.InsertUpload(steamid, common.Upload{Type: uploadType, Metadata: meta, Includes: includes, Data: body})
 	if err != nil {
 		utils.WriteError(w, r, fmt.Sprintf("failed to insert upload: %s", err))
 		return
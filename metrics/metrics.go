@@ -0,0 +1,124 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	requests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloudbox_http_requests_total",
+		Help: "HTTP requests handled, by route pattern, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cloudbox_http_request_duration_seconds",
+		Help:    "Time taken to handle HTTP requests, by route pattern.",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"route"})
+
+	responseBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloudbox_http_response_bytes_total",
+		Help: "Response body bytes written, by route pattern.",
+	}, []string{"route"})
+
+	storageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cloudbox_storage_operation_duration_seconds",
+		Help:    "Time taken by content store operations, by bucket and operation. Gets are timed until the body starts streaming.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"bucket", "operation"})
+
+	queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cloudbox_db_query_duration_seconds",
+		Help:    "Time taken by database queries, by query name.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"query"})
+
+	Logins = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cloudbox_logins_total",
+		Help: "Successful toybox logins.",
+	})
+
+	MapLoads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloudbox_map_loads_total",
+		Help: "Map loads recorded, by platform.",
+	}, []string{"platform"})
+
+	LuaErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloudbox_lua_errors_total",
+		Help: "Lua errors recorded, by realm.",
+	}, []string{"realm"})
+
+	DiscordEmbeds = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloudbox_discord_embeds_total",
+		Help: "Discord embeds handled by the notification queue, by webhook and result (sent, dropped or failed).",
+	}, []string{"webhook", "result"})
+
+	DiscordRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloudbox_discord_retries_total",
+		Help: "Discord webhook requests retried, by webhook and reason (rate_limited or error).",
+	}, []string{"webhook", "reason"})
+
+	DiscordQueueLength = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cloudbox_discord_queue_length",
+		Help: "Embeds waiting in the discord notification queue, by webhook.",
+	}, []string{"webhook"})
+
+	Events = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloudbox_events_total",
+		Help: "Events handled by outbound sinks, by sink, event type and result (sent, dropped or failed).",
+	}, []string{"sink", "type", "result"})
+)
+
+// ObserveRequest records a handled http request
+// route is the mux pattern, empty when nothing matched
+func ObserveRequest(route string, method string, status int, bytes int64, latency time.Duration) {
+	// unmatched paths are arbitrary, don't let them create new series
+	if route == "" {
+		route = "unmatched"
+	}
+
+	requests.WithLabelValues(route, methodLabel(method), strconv.Itoa(status)).Inc()
+	requestDuration.WithLabelValues(route).Observe(latency.Seconds())
+	responseBytes.WithLabelValues(route).Add(float64(bytes))
+}
+
+// methodLabel returns method if it's one of the standard ones and "OTHER" if not,
+// clients can send any token as a method and each would otherwise be a new series
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+
+	return "OTHER"
+}
+
+// ObserveQuery records how long a database query took
+// use as: defer metrics.ObserveQuery("name", time.Now())
+func ObserveQuery(name string, start time.Time) {
+	queryDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+}
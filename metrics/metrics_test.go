@@ -0,0 +1,38 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package metrics
+
+import "testing"
+
+func TestMethodLabel(t *testing.T) {
+	for method, want := range map[string]string{
+		"GET":      "GET",
+		"HEAD":     "HEAD",
+		"POST":     "POST",
+		"OPTIONS":  "OPTIONS",
+		"PROPFIND": "OTHER",
+		"get":      "OTHER", // methods are case sensitive
+		"":         "OTHER",
+		"X-\x00":   "OTHER",
+	} {
+		if got := methodLabel(method); got != want {
+			t.Fatalf("got %q for %q, want %q", got, method, want)
+		}
+	}
+}
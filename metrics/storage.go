@@ -0,0 +1,85 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package metrics
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/flatgrassdotnet/cloudbox/storage"
+)
+
+// instrumentedStore times every operation of a content store
+type instrumentedStore struct {
+	storage.ContentStore
+
+	bucket string
+}
+
+// InstrumentStore wraps s so its operations are recorded under bucket
+func InstrumentStore(s storage.ContentStore, bucket string) storage.ContentStore {
+	return &instrumentedStore{ContentStore: s, bucket: bucket}
+}
+
+func (s *instrumentedStore) observe(operation string, start time.Time) {
+	storageDuration.WithLabelValues(s.bucket, operation).Observe(time.Since(start).Seconds())
+}
+
+func (s *instrumentedStore) Get(ctx context.Context, key string) (*storage.Object, error) {
+	defer s.observe("get", time.Now())
+
+	return s.ContentStore.Get(ctx, key)
+}
+
+func (s *instrumentedStore) GetRange(ctx context.Context, key string, rng storage.Range) (*storage.Object, error) {
+	defer s.observe("get_range", time.Now())
+
+	return s.ContentStore.GetRange(ctx, key, rng)
+}
+
+func (s *instrumentedStore) Put(ctx context.Context, key string, body io.Reader, opts storage.PutOptions) error {
+	defer s.observe("put", time.Now())
+
+	return s.ContentStore.Put(ctx, key, body, opts)
+}
+
+func (s *instrumentedStore) Stat(ctx context.Context, key string) (storage.ObjectInfo, error) {
+	defer s.observe("stat", time.Now())
+
+	return s.ContentStore.Stat(ctx, key)
+}
+
+func (s *instrumentedStore) Delete(ctx context.Context, key string) error {
+	defer s.observe("delete", time.Now())
+
+	return s.ContentStore.Delete(ctx, key)
+}
+
+func (s *instrumentedStore) List(ctx context.Context, prefix string, fn func(storage.ObjectInfo) error) error {
+	defer s.observe("list", time.Now())
+
+	return s.ContentStore.List(ctx, prefix, fn)
+}
+
+func (s *instrumentedStore) Ping(ctx context.Context) error {
+	defer s.observe("ping", time.Now())
+
+	return s.ContentStore.Ping(ctx)
+}
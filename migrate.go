@@ -0,0 +1,89 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/flatgrassdotnet/cloudbox/db"
+)
+
+// runMigrate handles "cloudbox migrate up|down [n]|status"
+func runMigrate(database *db.DB, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up|down [n]|status")
+	}
+
+	switch args[0] {
+	case "up":
+		done, err := database.MigrateUp()
+		for _, m := range done {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(done) == 0 {
+			fmt.Println("schema is up to date")
+		}
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			var err error
+			n, err = strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid migration count: %s", args[1])
+			}
+		}
+
+		done, err := database.MigrateDown(n)
+		for _, m := range done {
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(done) == 0 {
+			fmt.Println("no migrations to revert")
+		}
+	case "status":
+		status, err := database.FetchMigrationStatus()
+		if err != nil {
+			return err
+		}
+
+		for _, s := range status {
+			applied := "pending"
+			if !s.Applied.IsZero() {
+				applied = s.Applied.Format(time.RFC3339)
+			}
+
+			fmt.Printf("%04d_%s\t%s\n", s.Version, s.Name, applied)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command: %s", args[0])
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"net/http"
+
+	"github.com/flatgrassdotnet/cloudbox/api/auth"
+	"github.com/flatgrassdotnet/cloudbox/api/content"
+	"github.com/flatgrassdotnet/cloudbox/api/news"
+	"github.com/flatgrassdotnet/cloudbox/api/packages"
+	"github.com/flatgrassdotnet/cloudbox/deps"
+	"github.com/flatgrassdotnet/cloudbox/ingame/publishsave"
+	"github.com/flatgrassdotnet/cloudbox/ingame/stats"
+	"github.com/flatgrassdotnet/cloudbox/ingame/toyboxapi"
+)
+
+// router is what routes registers handlers on, *http.ServeMux in main
+// tests wrap it to find out which routes exist
+type router interface {
+	HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request))
+}
+
+// routes registers every cloudbox and toybox endpoint on mux
+func routes(mux router, d deps.Deps) {
+	au := deps.New[auth.Handler](d)
+	nw := deps.New[news.Handler](d)
+	pk := deps.New[packages.Handler](d)
+	ct := deps.New[content.Handler](d)
+	st := deps.New[stats.Handler](d)
+	tb := deps.New[toyboxapi.Handler](d)
+	ps := deps.New[publishsave.Handler](d)
+
+	// cloudbox api
+	mux.HandleFunc("GET /auth/getid", au.GetID)
+	mux.HandleFunc("GET /news/list", nw.List)
+	mux.HandleFunc("GET /packages/list", pk.List)
+	mux.HandleFunc("GET /packages/listall", pk.ListAll)
+	mux.HandleFunc("GET /packages/get", pk.Get)
+	mux.HandleFunc("GET /packages/getscript", pk.GetScript)
+	mux.HandleFunc("GET /packages/getgma", pk.GetGMA)
+	mux.HandleFunc("GET /packages/getgma/manifest", pk.GetGMAManifest)
+	mux.HandleFunc("GET /content/get", ct.Get)
+	mux.HandleFunc("GET /content/getzip", ct.GetZIP)
+	mux.HandleFunc("GET /content/fastdl", ct.FastDL)
+
+	// stats.garrysmod.com (routed to toyboxapi)
+	mux.HandleFunc("GET toyboxapi.garrysmod.com/mapload_001/", st.MapLoad) // v102 - v142
+
+	// toyboxapi.garrysmod.com
+	// auth
+	mux.HandleFunc("GET toyboxapi.garrysmod.com/auth_001/", tb.Auth)  // v104 - v106
+	mux.HandleFunc("POST toyboxapi.garrysmod.com/auth_002/", tb.Auth) // v107 - v133
+	mux.HandleFunc("POST toyboxapi.garrysmod.com/auth_003/", tb.Auth) // v134 - v142
+
+	// getinstall
+	mux.HandleFunc("GET toyboxapi.garrysmod.com/getinstall_003/", tb.GetPackage) // v134 - v142
+
+	// getscript
+	mux.HandleFunc("GET toyboxapi.garrysmod.com/getscript_001/", tb.GetPackage) // v100 - v133
+	mux.HandleFunc("GET toyboxapi.garrysmod.com/getscript_003/", tb.GetPackage) // v134 - v142
+
+	// upload
+	mux.HandleFunc("POST toyboxapi.garrysmod.com/upload_001/", tb.Upload) // v109 - v133
+	mux.HandleFunc("POST toyboxapi.garrysmod.com/upload_003/", tb.Upload) // v134 - v142
+
+	// error
+	mux.HandleFunc("GET toyboxapi.garrysmod.com/error_001/", tb.Error) // v98 - v133
+	mux.HandleFunc("GET toyboxapi.garrysmod.com/error_003/", tb.Error) // v134 - v142
+
+	// publishsave
+	mux.HandleFunc("GET toyboxapi.garrysmod.com/publishsave_001/", ps.Save) // v106 - v108
+	mux.HandleFunc("GET toyboxapi.garrysmod.com/publishsave_002/", ps.Save) // v109 - v142
+
+	mux.HandleFunc("POST toyboxapi.garrysmod.com/publishsave_001/", ps.Publish) // v106 - v108
+	mux.HandleFunc("POST toyboxapi.garrysmod.com/publishsave_002/", ps.Publish) // v109 - v142
+}
@@ -0,0 +1,613 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/flatgrassdotnet/cloudbox/common"
+	"github.com/flatgrassdotnet/cloudbox/deps/fake"
+	"github.com/flatgrassdotnet/cloudbox/events"
+	"github.com/flatgrassdotnet/cloudbox/gma"
+)
+
+const (
+	testSteamID = "76561197960287930"
+	testTicket  = "steam-ticket"
+)
+
+// recordingMux remembers every pattern routes registers
+type recordingMux struct {
+	*http.ServeMux
+	patterns []string
+}
+
+func (m *recordingMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	m.patterns = append(m.patterns, pattern)
+	m.ServeMux.HandleFunc(pattern, handler)
+}
+
+type testEnv struct {
+	mux   *recordingMux
+	store *fake.Store
+	pub   *fake.Publisher
+}
+
+func newTestEnv() testEnv {
+	store := fake.NewStore()
+	store.Packages = []common.Package{
+		{
+			ID:       1,
+			Revision: 1,
+			Type:     "map",
+			Name:     "Test Map",
+			Dataname: "gm_test",
+			Author:   testSteamID,
+			Data:     []byte("map script"),
+			Content: []common.Content{
+				{ID: 1, Revision: 1, Path: "maps/gm_test.bsp", Size: 5},
+				{ID: 2, Revision: 1, Path: "readme.txt", Size: 2},
+			},
+		},
+	}
+	// file 2 hasn't been hashed yet, like files from before checksums were stored
+	store.Files[1] = fake.File{Path: "maps/gm_test.bsp", Data: []byte("hello"), CRC: crc32.ChecksumIEEE([]byte("hello")), SHA256: sha256Hex("hello")}
+	store.Files[2] = fake.File{Path: "readme.txt", Data: []byte("hi")}
+	store.News = []common.NewsEntry{{ID: 1, Title: "Welcome back"}}
+	store.Logins[testSteamID] = []byte("session")
+
+	steam := &fake.Steam{
+		Tickets:   map[string]string{testTicket: testSteamID},
+		Summaries: map[string]common.PlayerSummaryInfo{testSteamID: {SteamID: testSteamID, PersonaName: "garry"}},
+	}
+
+	pub := &fake.Publisher{}
+
+	mux := &recordingMux{ServeMux: http.NewServeMux()}
+	routes(mux, fake.Deps(store, steam, pub))
+
+	return testEnv{mux: mux, store: store, pub: pub}
+}
+
+// zipEquals checks for a zip holding one file named "file" with contents want
+func zipEquals(want string) func(t *testing.T, env testEnv, res *http.Response, body []byte) {
+	return func(t *testing.T, env testEnv, res *http.Response, body []byte) {
+		zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+		if err != nil {
+			t.Fatalf("invalid zip: %s", err)
+		}
+
+		if len(zr.File) != 1 || zr.File[0].Name != "file" {
+			t.Fatalf("unexpected zip entries: %+v", zr.File)
+		}
+
+		f, err := zr.File[0].Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := io.ReadAll(f)
+		if err != nil || string(data) != want {
+			t.Errorf("got contents %q, %v", data, err)
+		}
+	}
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// binhex encodes toybox parameters the way garry's mod does
+func binhex(s string) string {
+	return hex.EncodeToString([]byte(s))
+}
+
+// signed appends the key toybox requests end with
+// utils.ValidateKey rejects the real md5 of the request and accepts anything else, so any value does here
+func signed(values url.Values) string {
+	return values.Encode() + "&key=0"
+}
+
+// tga is an uncompressed 1x1 truecolor image with a tga 2.0 footer, what the game uploads as save thumbnails
+func tga() []byte {
+	img := []byte{0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 1, 0, 24, 0, 0xff, 0x80, 0x00}
+	img = append(img, make([]byte, 8)...) // no extension or developer area
+
+	return append(img, "TRUEVISION-XFILE.\x00"...)
+}
+
+type routeTest struct {
+	name    string
+	method  string
+	target  string
+	header  map[string]string
+	body    string
+	setup   func(env testEnv)
+	status  int
+	check   func(t *testing.T, env testEnv, res *http.Response, body []byte)
+	pattern string // filled in by the mux
+}
+
+func routeTests() []routeTest {
+	getPackage := url.Values{"u": {binhex(testSteamID)}, "scriptid": {binhex("1")}, "rev": {binhex("1")}}
+	mapLoad := url.Values{"u": {binhex(testSteamID)}, "time": {binhex("2.5")}, "map": {binhex("gm_test")}, "platform": {binhex("win32")}}
+	luaError := url.Values{"u": {binhex(testSteamID)}, "error": {binhex("attempt to index nil")}, "content": {binhex("x")}, "realm": {binhex("client")}, "platform": {binhex("linux")}}
+	auth := url.Values{"token": {binhex(testTicket)}, "u": {binhex(testSteamID)}, "vac": {binhex("0")}}
+
+	checkScript := func(t *testing.T, env testEnv, res *http.Response, body []byte) {
+		if !bytes.Contains(body, []byte(`"scriptid"`)) {
+			t.Errorf("body is not a package script: %q", body)
+		}
+
+		assertEvent(t, env, events.TypePackageDownload)
+	}
+
+	checkLogin := func(t *testing.T, env testEnv, res *http.Response, body []byte) {
+		ticket, err := base64.StdEncoding.DecodeString(string(body))
+		if err != nil || len(ticket) != 24 {
+			t.Errorf("body is not a login ticket: %q", body)
+		}
+
+		assertEvent(t, env, events.TypeLogin)
+	}
+
+	checkError := func(t *testing.T, env testEnv, res *http.Response, body []byte) {
+		if len(env.store.Errors) != 1 || env.store.Errors[0].Realm != "client" {
+			t.Errorf("error not recorded: %+v", env.store.Errors)
+		}
+
+		assertEvent(t, env, events.TypeLuaError)
+	}
+
+	checkUpload := func(t *testing.T, env testEnv, res *http.Response, body []byte) {
+		if string(body) != "1" {
+			t.Errorf("got upload id %q, want 1", body)
+		}
+
+		u, ok := env.store.Uploads[1]
+		if !ok || string(u.Data) != "save data" || len(u.Includes) != 1 || u.Includes[0] != 1 {
+			t.Errorf("upload not stored: %+v", u)
+		}
+	}
+
+	setupPublish := func(env testEnv) {
+		env.store.Uploads[1] = common.Upload{Type: "save", Metadata: "gm_test", Includes: []int{1}, Data: []byte("save data")}
+		env.store.Uploads[2] = common.Upload{Type: "save_image", Data: tga()}
+	}
+
+	checkPublish := func(t *testing.T, env testEnv, res *http.Response, body []byte) {
+		if len(env.store.Packages) != 2 || env.store.Packages[1].Type != "savemap" || env.store.Packages[1].Name != "My Save" {
+			t.Errorf("save not published: %+v", env.store.Packages)
+		}
+
+		if len(env.store.Thumbnails[2]) == 0 {
+			t.Error("thumbnail not stored")
+		}
+
+		if len(env.store.Uploads) != 0 {
+			t.Errorf("uploads not cleaned up: %+v", env.store.Uploads)
+		}
+
+		assertEvent(t, env, events.TypeSavePublished)
+	}
+
+	publishForm := url.Values{"name": {"My Save"}, "desc": {"a save"}}.Encode()
+	publishHeader := map[string]string{
+		"Content-Type": "application/x-www-form-urlencoded",
+		"TICKET":       base64.StdEncoding.EncodeToString([]byte("session")),
+	}
+
+	return []routeTest{
+		{
+			name:   "auth getid",
+			method: "GET", target: "/auth/getid?ticket=" + url.QueryEscape(base64.StdEncoding.EncodeToString([]byte("session"))),
+			status: http.StatusOK,
+			check: func(t *testing.T, env testEnv, res *http.Response, body []byte) {
+				if string(body) != testSteamID {
+					t.Errorf("got %q, want %s", body, testSteamID)
+				}
+			},
+		},
+		{
+			name:   "auth getid unknown ticket",
+			method: "GET", target: "/auth/getid?ticket=AAAA",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "news list",
+			method: "GET", target: "/news/list",
+			status: http.StatusOK,
+			check:  bodyContains(`"title":"Welcome back"`),
+		},
+		{
+			name:   "packages list",
+			method: "GET", target: "/packages/list?type=map",
+			status: http.StatusOK,
+			check:  bodyContains(`"name":"Test Map"`),
+		},
+		{
+			name:   "packages listall",
+			method: "GET", target: "/packages/listall",
+			status: http.StatusOK,
+			check:  bodyContains(`"name":"Test Map"`),
+		},
+		{
+			name:   "packages get",
+			method: "GET", target: "/packages/get?id=1",
+			status: http.StatusOK,
+			check:  bodyContains(`"dataname":"gm_test"`),
+		},
+		{
+			name:   "packages get missing",
+			method: "GET", target: "/packages/get?id=1&rev=9",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "packages getscript",
+			method: "GET", target: "/packages/getscript?id=1",
+			status: http.StatusOK,
+			check:  bodyContains("map script"),
+		},
+		{
+			name:   "packages getgma",
+			method: "GET", target: "/packages/getgma?id=1",
+			status: http.StatusOK,
+			check: func(t *testing.T, env testEnv, res *http.Response, body []byte) {
+				if res.Header.Get("X-Cloudbox-Excluded") != "1" {
+					t.Errorf("got X-Cloudbox-Excluded %q, want 1", res.Header.Get("X-Cloudbox-Excluded"))
+				}
+
+				gr, err := gma.NewReader(bytes.NewReader(body))
+				if err != nil {
+					t.Fatalf("invalid gma: %s", err)
+				}
+
+				f, err := gr.Next()
+				if err != nil || f.Name != "maps/gm_test.bsp" {
+					t.Fatalf("got file %+v, %v", f, err)
+				}
+
+				data, err := io.ReadAll(gr)
+				if err != nil || string(data) != "hello" {
+					t.Errorf("got contents %q, %v", data, err)
+				}
+
+				_, err = gr.Next()
+				if err != io.EOF {
+					t.Errorf("got %v after the last file, want io.EOF", err)
+				}
+			},
+		},
+		{
+			name:   "packages getgma manifest",
+			method: "GET", target: "/packages/getgma/manifest?id=1",
+			status: http.StatusOK,
+			check: func(t *testing.T, env testEnv, res *http.Response, body []byte) {
+				var m common.GMAManifest
+				err := json.Unmarshal(body, &m)
+				if err != nil {
+					t.Fatalf("invalid manifest: %s", err)
+				}
+
+				if len(m.Included) != 1 || m.Included[0].Rule != "maps/*.bsp" || len(m.Excluded) != 1 || m.Excluded[0].Path != "readme.txt" {
+					t.Errorf("unexpected manifest: %+v", m)
+				}
+			},
+		},
+		{
+			name:   "content get",
+			method: "GET", target: "/content/get?id=1",
+			status: http.StatusOK,
+			check: func(t *testing.T, env testEnv, res *http.Response, body []byte) {
+				bodyEquals("hello")(t, env, res, body)
+
+				if etag := res.Header.Get("ETag"); etag != `"`+sha256Hex("hello")+`"` {
+					t.Errorf("got etag %q", etag)
+				}
+			},
+		},
+		{
+			name:   "content get unhashed",
+			method: "GET", target: "/content/get?id=2",
+			status: http.StatusOK,
+			check: func(t *testing.T, env testEnv, res *http.Response, body []byte) {
+				bodyEquals("hi")(t, env, res, body)
+
+				if etag := res.Header.Get("ETag"); etag != "" {
+					t.Errorf("got etag %q for a file without stored sums", etag)
+				}
+
+				// hashed on the way out
+				if f := env.store.Files[2]; f.SHA256 != sha256Hex("hi") || f.CRC != crc32.ChecksumIEEE([]byte("hi")) {
+					t.Errorf("sums not stored: %+v", f)
+				}
+			},
+		},
+		{
+			name:   "content get unhashed range",
+			method: "GET", target: "/content/get?id=2",
+			header: map[string]string{"Range": "bytes=1-1"},
+			status: http.StatusPartialContent,
+			check: func(t *testing.T, env testEnv, res *http.Response, body []byte) {
+				bodyEquals("i")(t, env, res, body)
+
+				if f := env.store.Files[2]; f.SHA256 != "" {
+					t.Errorf("stored sums from part of the file: %+v", f)
+				}
+			},
+		},
+		{
+			name:   "content get range",
+			method: "GET", target: "/content/get?id=1",
+			header: map[string]string{"Range": "bytes=1-3"},
+			status: http.StatusPartialContent,
+			check:  bodyEquals("ell"),
+		},
+		{
+			name:   "content getzip",
+			method: "GET", target: "/content/getzip?id=1",
+			status: http.StatusOK,
+			check: func(t *testing.T, env testEnv, res *http.Response, body []byte) {
+				zipEquals("hello")(t, env, res, body)
+
+				if etag := res.Header.Get("ETag"); etag != `"`+sha256Hex("hello")+`-zip"` {
+					t.Errorf("got etag %q", etag)
+				}
+			},
+		},
+		{
+			name:   "content getzip unhashed",
+			method: "GET", target: "/content/getzip?id=2",
+			status: http.StatusOK,
+			check: func(t *testing.T, env testEnv, res *http.Response, body []byte) {
+				// archive/zip checks the crc in the data descriptor
+				zipEquals("hi")(t, env, res, body)
+
+				if res.Header.Get("Content-Length") != strconv.Itoa(len(body)) {
+					t.Errorf("got content length %q for %d bytes", res.Header.Get("Content-Length"), len(body))
+				}
+
+				if f := env.store.Files[2]; f.SHA256 != sha256Hex("hi") {
+					t.Errorf("sums not stored: %+v", f)
+				}
+			},
+		},
+		{
+			name:   "content fastdl",
+			method: "GET", target: "/content/fastdl?file=/maps/gm_test.bsp",
+			status: http.StatusOK,
+			check:  bodyEquals("hello"),
+		},
+		{
+			name:   "content fastdl missing",
+			method: "GET", target: "/content/fastdl?file=maps/nope.bsp",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "mapload",
+			method: "GET", target: "http://toyboxapi.garrysmod.com/mapload_001/?" + signed(mapLoad),
+			status: http.StatusOK,
+			check: func(t *testing.T, env testEnv, res *http.Response, body []byte) {
+				if len(env.store.MapLoads) != 1 || env.store.MapLoads[0].Map != "gm_test" || env.store.MapLoads[0].Duration != 2.5 {
+					t.Errorf("map load not recorded: %+v", env.store.MapLoads)
+				}
+
+				assertEvent(t, env, events.TypeMapLoad)
+			},
+		},
+		{
+			name:   "auth 001",
+			method: "GET", target: "http://toyboxapi.garrysmod.com/auth_001/?" + signed(auth),
+			status: http.StatusOK,
+			check:  checkLogin,
+		},
+		{
+			name:   "auth 002",
+			method: "POST", target: "http://toyboxapi.garrysmod.com/auth_002/",
+			header: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			body:   signed(auth),
+			status: http.StatusOK,
+			check:  checkLogin,
+		},
+		{
+			name:   "auth 003",
+			method: "POST", target: "http://toyboxapi.garrysmod.com/auth_003/",
+			header: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			body:   signed(auth),
+			status: http.StatusOK,
+			check:  checkLogin,
+		},
+		{
+			name:   "auth 003 wrong steamid",
+			method: "POST", target: "http://toyboxapi.garrysmod.com/auth_003/",
+			header: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			body:   signed(url.Values{"token": {binhex(testTicket)}, "u": {binhex("76561197960287931")}, "vac": {binhex("0")}}),
+			status: http.StatusOK,
+			check:  bodyEquals("chrome"),
+		},
+		{
+			name:   "getinstall 003",
+			method: "GET", target: "http://toyboxapi.garrysmod.com/getinstall_003/?" + signed(getPackage),
+			status: http.StatusOK,
+			check:  checkScript,
+		},
+		{
+			name:   "getscript 001",
+			method: "GET", target: "http://toyboxapi.garrysmod.com/getscript_001/?" + signed(getPackage),
+			status: http.StatusOK,
+			check:  checkScript,
+		},
+		{
+			name:   "getscript 003",
+			method: "GET", target: "http://toyboxapi.garrysmod.com/getscript_003/?" + signed(getPackage),
+			status: http.StatusOK,
+			check:  checkScript,
+		},
+		{
+			name:   "upload 001",
+			method: "POST", target: "http://toyboxapi.garrysmod.com/upload_001/?steamid=" + testSteamID + "&type=save&meta=gm_test&inc=1,",
+			body:   base64.StdEncoding.EncodeToString([]byte("save data")),
+			status: http.StatusOK,
+			check:  checkUpload,
+		},
+		{
+			name:   "upload 003",
+			method: "POST", target: "http://toyboxapi.garrysmod.com/upload_003/?steamid=" + testSteamID + "&type=save&meta=gm_test&inc=1",
+			body:   base64.StdEncoding.EncodeToString([]byte("save data")),
+			status: http.StatusOK,
+			check:  checkUpload,
+		},
+		{
+			name:   "upload invalid type",
+			method: "POST", target: "http://toyboxapi.garrysmod.com/upload_003/?steamid=" + testSteamID + "&type=dupe",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "error 001",
+			method: "GET", target: "http://toyboxapi.garrysmod.com/error_001/?" + signed(luaError),
+			status: http.StatusOK,
+			check:  checkError,
+		},
+		{
+			name:   "error 003",
+			method: "GET", target: "http://toyboxapi.garrysmod.com/error_003/?" + signed(luaError),
+			status: http.StatusOK,
+			check:  checkError,
+		},
+		{
+			name:   "publishsave 001 form",
+			method: "GET", target: "http://toyboxapi.garrysmod.com/publishsave_001/?id=1",
+			status: http.StatusOK,
+		},
+		{
+			name:   "publishsave 002 form",
+			method: "GET", target: "http://toyboxapi.garrysmod.com/publishsave_002/?id=1&sid=2",
+			status: http.StatusOK,
+		},
+		{
+			name:   "publishsave 002 form without sid",
+			method: "GET", target: "http://toyboxapi.garrysmod.com/publishsave_002/?id=1",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "publishsave 001 publish",
+			method: "POST", target: "http://toyboxapi.garrysmod.com/publishsave_001/?id=1&sid=2",
+			header: publishHeader,
+			body:   publishForm,
+			setup:  setupPublish,
+			status: http.StatusOK,
+			check:  checkPublish,
+		},
+		{
+			name:   "publishsave 002 publish",
+			method: "POST", target: "http://toyboxapi.garrysmod.com/publishsave_002/?id=1&sid=2",
+			header: publishHeader,
+			body:   publishForm,
+			setup:  setupPublish,
+			status: http.StatusOK,
+			check:  checkPublish,
+		},
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	for _, tt := range routeTests() {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv()
+			if tt.setup != nil {
+				tt.setup(env)
+			}
+
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+
+			rec := httptest.NewRecorder()
+			env.mux.ServeHTTP(rec, req)
+
+			res := rec.Result()
+			body, _ := io.ReadAll(res.Body)
+
+			if res.StatusCode != tt.status {
+				t.Fatalf("got status %d, want %d: %s", res.StatusCode, tt.status, body)
+			}
+
+			if tt.check != nil {
+				tt.check(t, env, res, body)
+			}
+		})
+	}
+}
+
+// TestRoutesCovered fails when a route is registered without a test above
+func TestRoutesCovered(t *testing.T) {
+	env := newTestEnv()
+
+	covered := make(map[string]bool)
+	for _, tt := range routeTests() {
+		_, pattern := env.mux.Handler(httptest.NewRequest(tt.method, tt.target, nil))
+		covered[pattern] = true
+	}
+
+	for _, pattern := range env.mux.patterns {
+		if !covered[pattern] {
+			t.Errorf("no test for %s", pattern)
+		}
+	}
+}
+
+func bodyEquals(want string) func(t *testing.T, env testEnv, res *http.Response, body []byte) {
+	return func(t *testing.T, env testEnv, res *http.Response, body []byte) {
+		if string(body) != want {
+			t.Errorf("got body %q, want %q", body, want)
+		}
+	}
+}
+
+func bodyContains(want string) func(t *testing.T, env testEnv, res *http.Response, body []byte) {
+	return func(t *testing.T, env testEnv, res *http.Response, body []byte) {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("body %q doesn't contain %q", body, want)
+		}
+	}
+}
+
+func assertEvent(t *testing.T, env testEnv, want events.Type) {
+	t.Helper()
+
+	if len(env.pub.Events) != 1 || env.pub.Events[0].Type() != want {
+		t.Errorf("got events %+v, want one %s", env.pub.Events, want)
+	}
+}
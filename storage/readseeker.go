@@ -0,0 +1,127 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// readWindow is how much the first ranged get after a seek asks for when a Read needs less
+// each following get asks for twice as much, so reading n bytes takes about log2(n/readWindow) gets
+// while a small range request still only fetches a little past what it needs
+const readWindow = 1 << 20
+
+// ReadSeeker reads an object through ranged gets, so http.ServeContent can
+// serve byte ranges without fetching the whole object first
+// nothing is fetched until the first Read, seeking drops the current body
+type ReadSeeker struct {
+	ctx   context.Context
+	store ContentStore
+	key   string
+	size  int64
+
+	pos    int64
+	end    int64 // where body stops
+	window int64 // size of the next get, 0 after a seek
+	body   io.ReadCloser
+}
+
+func NewReadSeeker(ctx context.Context, store ContentStore, key string, size int64) *ReadSeeker {
+	return &ReadSeeker{ctx: ctx, store: store, key: key, size: size}
+}
+
+func (r *ReadSeeker) Read(p []byte) (int, error) {
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.body == nil {
+		if r.window == 0 {
+			r.window = readWindow
+		}
+
+		length := min(max(int64(len(p)), r.window), r.size-r.pos)
+
+		o, err := r.store.GetRange(r.ctx, r.key, Range{Offset: r.pos, Length: length})
+		if err != nil {
+			return 0, err
+		}
+
+		r.body = o.Body
+		r.end = r.pos + length
+
+		// the size is never more than what's left, stop before it could overflow
+		if r.window < r.size {
+			r.window *= 2
+		}
+	}
+
+	n, err := r.body.Read(p)
+	r.pos += int64(n)
+
+	switch {
+	case r.pos >= r.end:
+		// the next Read gets the next window
+		r.Close()
+		if err == io.EOF {
+			err = nil
+		}
+	case err == io.EOF:
+		err = io.ErrUnexpectedEOF
+	}
+
+	return n, err
+}
+
+func (r *ReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	pos := r.pos
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos += offset
+	case io.SeekEnd:
+		pos = r.size + offset
+	}
+
+	if pos < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	if pos != r.pos {
+		r.Close()
+		r.pos = pos
+		r.window = 0
+	}
+
+	return pos, nil
+}
+
+func (r *ReadSeeker) Close() error {
+	if r.body == nil {
+		return nil
+	}
+
+	err := r.body.Close()
+	r.body = nil
+
+	return err
+}
@@ -0,0 +1,160 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+// countingStore records the ranges asked for
+type countingStore struct {
+	*LocalStore
+	ranges []Range
+}
+
+func (s *countingStore) GetRange(ctx context.Context, key string, rng Range) (*Object, error) {
+	s.ranges = append(s.ranges, rng)
+
+	return s.LocalStore.GetRange(ctx, key, rng)
+}
+
+func newTestObject(t *testing.T, size int) (*countingStore, []byte) {
+	t.Helper()
+
+	local, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %s", err)
+	}
+
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	err = local.Put(context.Background(), "obj", bytes.NewReader(data), PutOptions{})
+	if err != nil {
+		t.Fatalf("failed to put: %s", err)
+	}
+
+	return &countingStore{LocalStore: local}, data
+}
+
+func TestReadSeekerWindows(t *testing.T) {
+	size := readWindow*7 + 100
+	store, data := newTestObject(t, size)
+
+	r := NewReadSeeker(context.Background(), store, "obj", int64(size))
+	defer r.Close()
+
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to read: %s", err)
+	}
+
+	if !bytes.Equal(got, data) {
+		t.Fatalf("got %d bytes that don't match", len(got))
+	}
+
+	// each get is twice the size of the last
+	want := []Range{{0, readWindow}, {readWindow, readWindow * 2}, {readWindow * 3, readWindow * 4}, {readWindow * 7, 100}}
+	if len(store.ranges) != len(want) {
+		t.Fatalf("got ranges %v, want %v", store.ranges, want)
+	}
+
+	for i := range want {
+		if store.ranges[i] != want[i] {
+			t.Fatalf("got ranges %v, want %v", store.ranges, want)
+		}
+	}
+}
+
+func TestReadSeekerSeek(t *testing.T) {
+	size := readWindow * 3
+	store, data := newTestObject(t, size)
+
+	r := NewReadSeeker(context.Background(), store, "obj", int64(size))
+	defer r.Close()
+
+	_, err := r.Seek(-10, io.SeekEnd)
+	if err != nil {
+		t.Fatalf("failed to seek: %s", err)
+	}
+
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to read: %s", err)
+	}
+
+	if !bytes.Equal(got, data[size-10:]) {
+		t.Fatalf("got %x, want %x", got, data[size-10:])
+	}
+
+	// never more than what's left
+	if len(store.ranges) != 1 || store.ranges[0] != (Range{int64(size - 10), 10}) {
+		t.Fatalf("got ranges %v", store.ranges)
+	}
+
+	// reading on from the start grows the windows again
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		t.Fatalf("failed to seek: %s", err)
+	}
+
+	got, err = io.ReadAll(io.LimitReader(r, readWindow+1))
+	if err != nil || !bytes.Equal(got, data[:readWindow+1]) {
+		t.Fatalf("got %d bytes, %v", len(got), err)
+	}
+
+	want := []Range{{int64(size - 10), 10}, {0, readWindow}, {readWindow, readWindow * 2}}
+	if !slices.Equal(store.ranges, want) {
+		t.Fatalf("got ranges %v, want %v", store.ranges, want)
+	}
+}
+
+func TestReadSeekerServeContent(t *testing.T) {
+	size := readWindow * 4
+	store, data := newTestObject(t, size)
+
+	r := NewReadSeeker(context.Background(), store, "obj", int64(size))
+	defer r.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Range", "bytes=1000-1999")
+
+	rec := httptest.NewRecorder()
+	http.ServeContent(rec, req, "obj", time.Time{}, r)
+
+	if rec.Code != http.StatusPartialContent || !bytes.Equal(rec.Body.Bytes(), data[1000:2000]) {
+		t.Fatalf("got %d with %d bytes", rec.Code, rec.Body.Len())
+	}
+
+	// a small range is one bounded get, not the rest of the object
+	for _, rng := range store.ranges {
+		if rng.Length < 0 || rng.Length > readWindow {
+			t.Fatalf("got unbounded range %v", rng)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+type S3Store struct {
+	client *s3.Client
+	bucket string
+}
+
+func NewS3Store(client *s3.Client, bucket string) *S3Store {
+	return &S3Store{client: client, bucket: bucket}
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) (*Object, error) {
+	o, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, s3Error(err)
+	}
+
+	return &Object{
+		ObjectInfo: ObjectInfo{
+			Key:     key,
+			Size:    aws.ToInt64(o.ContentLength),
+			ModTime: aws.ToTime(o.LastModified),
+		},
+		Body: o.Body,
+	}, nil
+}
+
+func (s *S3Store) GetRange(ctx context.Context, key string, rng Range) (*Object, error) {
+	header := fmt.Sprintf("bytes=%d-", rng.Offset)
+	if rng.Length >= 0 {
+		header += strconv.FormatInt(rng.Offset+rng.Length-1, 10)
+	}
+
+	o, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(header),
+	})
+	if err != nil {
+		return nil, s3Error(err)
+	}
+
+	// Content-Range looks like "bytes 0-99/1234"
+	size := aws.ToInt64(o.ContentLength)
+	if _, total, ok := strings.Cut(aws.ToString(o.ContentRange), "/"); ok {
+		size, err = strconv.ParseInt(total, 10, 64)
+		if err != nil {
+			o.Body.Close()
+			return nil, fmt.Errorf("invalid content range: %s", aws.ToString(o.ContentRange))
+		}
+	}
+
+	return &Object{
+		ObjectInfo: ObjectInfo{
+			Key:     key,
+			Size:    size,
+			ModTime: aws.ToTime(o.LastModified),
+		},
+		Body: o.Body,
+	}, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, body io.Reader, opts PutOptions) error {
+	in := &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Body:   body,
+	}
+
+	if opts.ContentType != "" {
+		in.ContentType = aws.String(opts.ContentType)
+	}
+
+	if opts.Public {
+		in.ACL = types.ObjectCannedACLPublicRead
+	}
+
+	_, err := s.client.PutObject(ctx, in)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *S3Store) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	o, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return ObjectInfo{}, s3Error(err)
+	}
+
+	return ObjectInfo{
+		Key:     key,
+		Size:    aws.ToInt64(o.ContentLength),
+		ModTime: aws.ToTime(o.LastModified),
+	}, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return s3Error(err)
+	}
+
+	return nil
+}
+
+func (s *S3Store) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	p := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, o := range page.Contents {
+			err = fn(ObjectInfo{
+				Key:     aws.ToString(o.Key),
+				Size:    aws.ToInt64(o.Size),
+				ModTime: aws.ToTime(o.LastModified),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *S3Store) Ping(ctx context.Context) error {
+	_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(s.bucket),
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// s3Error translates missing object errors into ErrNotExist
+func s3Error(err error) error {
+	var nsk *types.NoSuchKey
+	var nf *types.NotFound
+	if errors.As(err, &nsk) || errors.As(err, &nf) {
+		return ErrNotExist
+	}
+
+	return err
+}
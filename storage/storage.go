@@ -0,0 +1,69 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var ErrNotExist = errors.New("object does not exist")
+
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+type Object struct {
+	ObjectInfo
+	Body io.ReadCloser
+}
+
+// Range selects part of an object, a negative Length reads to the end
+type Range struct {
+	Offset int64
+	Length int64
+}
+
+type PutOptions struct {
+	ContentType string
+	Public      bool // readable without credentials, used for images
+}
+
+// ContentStore is a flat key/value object store, such as an S3 bucket or a local directory
+type ContentStore interface {
+	Get(ctx context.Context, key string) (*Object, error)
+
+	// GetRange is like Get but Body only holds the requested range, Size is still that of the whole object
+	GetRange(ctx context.Context, key string, rng Range) (*Object, error)
+
+	Put(ctx context.Context, key string, body io.Reader, opts PutOptions) error
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+
+	// List calls fn for every object whose key starts with prefix
+	// returning an error from fn stops the listing and returns that error
+	List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error
+
+	// Ping checks that the store is reachable and the bucket or directory exists
+	Ping(ctx context.Context) error
+}
@@ -0,0 +1,51 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/flatgrassdotnet/cloudbox/config"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// newTLSConfig builds the https listener's config from either certificate files or acme
+// the returned function wraps the plain http handler, acme needs it to answer http-01 challenges
+func newTLSConfig(cfg *config.Config) (*tls.Config, func(http.Handler) http.Handler, error) {
+	if cfg.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		tc := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+		return tc, func(h http.Handler) http.Handler { return h }, nil
+	}
+
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(cfg.ACMEDir),
+		HostPolicy: autocert.HostWhitelist(cfg.ACMEHostList()...),
+		Email:      cfg.ACMEEmail,
+	}
+
+	return m.TLSConfig(), m.HTTPHandler, nil
+}
@@ -18,16 +18,8 @@
 
 package utils
 
-import (
-	"bytes"
-	"encoding/json"
-	"net/http"
-)
-
-var (
-	DiscordSaveWebhookURL  string
-	DiscordStatsWebhookURL string
-)
+// DiscordStatsQueue receives api error embeds from WriteError
+var DiscordStatsQueue *DiscordQueue
 
 type DiscordWebhookRequest struct {
 	Embeds          []DiscordWebhookEmbed `json:"embeds"`
@@ -42,6 +34,7 @@ type DiscordWebhookEmbed struct {
 	Color       int                       `json:"color"`
 	Author      DiscordWebhookEmbedAuthor `json:"author"`
 	Image       DiscordWebhookEmbedImage  `json:"image"`
+	Footer      DiscordWebhookEmbedFooter `json:"footer,omitzero"`
 }
 
 type DiscordWebhookEmbedAuthor struct {
@@ -53,22 +46,6 @@ type DiscordWebhookEmbedImage struct {
 	URL string `json:"url"`
 }
 
-func SendDiscordMessage(url string, data DiscordWebhookRequest) error {
-	if url == "" {
-		return nil
-	}
-
-	body, err := json.Marshal(data)
-	if err != nil {
-		return err
-	}
-
-	resp, err := http.Post(url, "application/json", bytes.NewReader(body))
-	if err != nil {
-		return err
-	}
-
-	defer resp.Body.Close()
-
-	return nil
+type DiscordWebhookEmbedFooter struct {
+	Text string `json:"text"`
 }
@@ -0,0 +1,301 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/flatgrassdotnet/cloudbox/metrics"
+)
+
+const (
+	discordMaxEmbeds   = 10   // per message
+	discordMaxChars    = 6000 // across all embeds of a message
+	discordMaxAttempts = 5
+	discordMaxWait     = time.Minute
+)
+
+var discordClient = &http.Client{Timeout: 15 * time.Second}
+
+// DiscordQueue delivers embeds to a webhook from a background goroutine
+// embeds that pile up while a message is being sent go out together in the next one
+type DiscordQueue struct {
+	name string
+	url  string
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan DiscordWebhookEmbed
+	done   chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewDiscordQueue starts a queue holding up to size embeds for url
+// name labels the queue in logs and metrics, an empty url discards everything
+func NewDiscordQueue(name string, url string, size int) *DiscordQueue {
+	q := &DiscordQueue{name: name, url: url}
+	if url == "" {
+		return q
+	}
+
+	q.queue = make(chan DiscordWebhookEmbed, size)
+	q.done = make(chan struct{})
+	q.ctx, q.cancel = context.WithCancel(context.Background())
+
+	go q.run()
+
+	return q
+}
+
+// Enqueue queues embed without blocking, it's dropped if the queue is full
+func (q *DiscordQueue) Enqueue(embed DiscordWebhookEmbed) {
+	if q == nil || q.queue == nil {
+		return
+	}
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		metrics.DiscordEmbeds.WithLabelValues(q.name, "dropped").Inc()
+		return
+	}
+
+	select {
+	case q.queue <- embed:
+		metrics.DiscordQueueLength.WithLabelValues(q.name).Inc()
+	default:
+		metrics.DiscordEmbeds.WithLabelValues(q.name, "dropped").Inc()
+		slog.Warn("discord queue full, dropping embed", "webhook", q.name, "title", embed.Title)
+	}
+}
+
+// Close stops accepting embeds and waits for the queued ones to be sent
+// whatever is left when ctx expires is dropped
+func (q *DiscordQueue) Close(ctx context.Context) error {
+	if q == nil || q.queue == nil {
+		return nil
+	}
+
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.queue)
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		<-q.done
+
+		return ctx.Err()
+	}
+}
+
+func (q *DiscordQueue) run() {
+	defer close(q.done)
+
+	var carry []DiscordWebhookEmbed
+	for {
+		batch := carry
+		carry = nil
+
+		if len(batch) == 0 {
+			embed, ok := q.next()
+			if !ok {
+				return
+			}
+
+			batch = append(batch, embed)
+		}
+
+		size := embedSize(batch[0])
+
+		// add whatever else is already waiting, as long as it fits in one message
+	fill:
+		for len(batch) < discordMaxEmbeds {
+			select {
+			case embed, ok := <-q.queue:
+				if !ok {
+					break fill
+				}
+
+				metrics.DiscordQueueLength.WithLabelValues(q.name).Dec()
+
+				if size+embedSize(embed) > discordMaxChars {
+					carry = append(carry, embed)
+					break fill
+				}
+
+				size += embedSize(embed)
+				batch = append(batch, embed)
+			default:
+				break fill
+			}
+		}
+
+		q.deliver(batch)
+	}
+}
+
+// next blocks until an embed is queued, ok is false once the queue is closed and empty
+func (q *DiscordQueue) next() (DiscordWebhookEmbed, bool) {
+	embed, ok := <-q.queue
+	if ok {
+		metrics.DiscordQueueLength.WithLabelValues(q.name).Dec()
+	}
+
+	return embed, ok
+}
+
+func (q *DiscordQueue) deliver(batch []DiscordWebhookEmbed) {
+	// shutdown timed out, don't bother trying
+	if q.ctx.Err() != nil {
+		metrics.DiscordEmbeds.WithLabelValues(q.name, "dropped").Add(float64(len(batch)))
+		return
+	}
+
+	err := q.send(batch)
+	if err != nil {
+		metrics.DiscordEmbeds.WithLabelValues(q.name, "failed").Add(float64(len(batch)))
+		slog.Error("failed to send discord webhook message", "webhook", q.name, "embeds", len(batch), "error", err)
+		return
+	}
+
+	metrics.DiscordEmbeds.WithLabelValues(q.name, "sent").Add(float64(len(batch)))
+}
+
+// send posts one message, retrying rate limited and failed requests
+func (q *DiscordQueue) send(batch []DiscordWebhookEmbed) error {
+	body, err := json.Marshal(DiscordWebhookRequest{Embeds: batch})
+	if err != nil {
+		return err
+	}
+
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		status, header, err := q.post(body)
+
+		var wait time.Duration
+		var reason string
+		switch {
+		case err != nil || status >= 500:
+			if err == nil {
+				err = fmt.Errorf("unexpected status code: %d", status)
+			}
+
+			wait = backoff
+			backoff *= 2
+			reason = "error"
+		case status == http.StatusTooManyRequests:
+			err = errors.New("rate limited")
+
+			wait = headerSeconds(header, "Retry-After")
+			if wait == 0 {
+				wait = backoff
+				backoff *= 2
+			}
+
+			reason = "rate_limited"
+		case status >= 300:
+			return fmt.Errorf("unexpected status code: %d", status)
+		default:
+			// bucket is empty, wait for it to refill instead of running into a 429
+			if header.Get("X-RateLimit-Remaining") == "0" {
+				q.sleep(headerSeconds(header, "X-RateLimit-Reset-After"))
+			}
+
+			return nil
+		}
+
+		if attempt == discordMaxAttempts {
+			return err
+		}
+
+		metrics.DiscordRetries.WithLabelValues(q.name, reason).Inc()
+
+		if !q.sleep(wait) {
+			return q.ctx.Err()
+		}
+	}
+}
+
+func (q *DiscordQueue) post(body []byte) (int, http.Header, error) {
+	req, err := http.NewRequestWithContext(q.ctx, http.MethodPost, q.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := discordClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	defer resp.Body.Close()
+
+	// drain so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	return resp.StatusCode, resp.Header, nil
+}
+
+// sleep waits for d, returns false if the queue was cancelled in the meantime
+func (q *DiscordQueue) sleep(d time.Duration) bool {
+	t := time.NewTimer(min(d, discordMaxWait))
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-q.ctx.Done():
+		return false
+	}
+}
+
+// headerSeconds parses a header holding a (possibly fractional) number of seconds
+func headerSeconds(h http.Header, key string) time.Duration {
+	s, err := strconv.ParseFloat(h.Get(key), 64)
+	if err != nil || s < 0 {
+		return 0
+	}
+
+	return time.Duration(s * float64(time.Second))
+}
+
+// embedSize approximates how many characters discord counts towards the per message limit
+func embedSize(e DiscordWebhookEmbed) int {
+	return len(e.Title) + len(e.Description) + len(e.Author.Name) + len(e.Footer.Text)
+}
@@ -20,23 +20,26 @@ package utils
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 )
 
 func WriteError(w http.ResponseWriter, r *http.Request, message string) {
-	log.Printf("%s: %s", r.URL, message)
+	id := RequestID(r)
+
+	slog.ErrorContext(r.Context(), message, "request_id", id, "url", r.URL.String())
 	w.WriteHeader(http.StatusBadRequest)
 
 	// webhook related
-	SendDiscordMessage(DiscordStatsWebhookURL, DiscordWebhookRequest{
-		Embeds: []DiscordWebhookEmbed{{
-			Title:       "API Error",
-			Description: fmt.Sprintf("%s: %s", r.URL, message),
-			Color:       0x7D0000,
-			Author: DiscordWebhookEmbedAuthor{
-				Name: UnBinHexString(r.FormValue("u")),
-			},
-		}},
+	DiscordStatsQueue.Enqueue(DiscordWebhookEmbed{
+		Title:       "API Error",
+		Description: fmt.Sprintf("%s: %s", r.URL, message),
+		Color:       0x7D0000,
+		Author: DiscordWebhookEmbedAuthor{
+			Name: UnBinHexString(r.FormValue("u")),
+		},
+		Footer: DiscordWebhookEmbedFooter{
+			Text: fmt.Sprintf("request %s", id),
+		},
 	})
 }
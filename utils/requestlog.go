@@ -0,0 +1,163 @@
+/*
+	cloudbox - the toybox server emulator
+	Copyright (C) 2024-2025  patapancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/flatgrassdotnet/cloudbox/metrics"
+)
+
+type requestIDKey struct{}
+
+// RequestID returns the id LogRequests assigned to r, if any
+func RequestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+
+	return id
+}
+
+// LogRequests assigns every request an id and logs it once it has been handled
+// an X-Request-ID header set by a fronting proxy is reused
+func LogRequests(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set("X-Request-ID", id)
+
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+		rw := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		h.ServeHTTP(rw, r)
+
+		latency := time.Since(start)
+		metrics.ObserveRequest(r.Pattern, r.Method, rw.status, rw.bytes, latency)
+
+		attrs := []slog.Attr{
+			slog.String("request_id", id),
+			slog.String("method", r.Method),
+			slog.String("host", r.Host),
+			slog.String("path", r.URL.Path),
+			slog.String("route", r.Pattern), // set by the mux
+			slog.Int("status", rw.status),
+			slog.Int64("bytes", rw.bytes),
+			slog.Duration("latency", latency),
+			slog.String("remote", r.RemoteAddr),
+		}
+
+		if steamid := requestSteamID(r); steamid != "" {
+			attrs = append(attrs, slog.String("steamid", steamid))
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case rw.status >= 500:
+			level = slog.LevelError
+		case rw.status >= 400:
+			level = slog.LevelWarn
+		}
+
+		slog.LogAttrs(r.Context(), level, "request", attrs...)
+	})
+}
+
+// requestSteamID decodes the "u" value toybox requests carry
+// the body is only looked at if the handler already parsed it
+func requestSteamID(r *http.Request) string {
+	u := r.URL.Query().Get("u")
+	if u == "" && r.Form != nil {
+		u = r.Form.Get("u")
+	}
+
+	return UnBinHexString(u)
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// statusRecorder remembers the status code and body size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+
+	return n, err
+}
+
+// ReadFrom keeps io.Copy able to use sendfile for local content
+func (w *statusRecorder) ReadFrom(r io.Reader) (int64, error) {
+	w.wroteHeader = true
+
+	n, err := io.Copy(w.ResponseWriter, r)
+	w.bytes += n
+
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -30,10 +30,19 @@ import (
 	"slices"
 
 	"github.com/flatgrassdotnet/cloudbox/common"
-	"github.com/flatgrassdotnet/cloudbox/db"
 )
 
-var SteamAPIKey string
+// ProfileCache stores player summaries so they aren't fetched from steam every time
+type ProfileCache interface {
+	FetchPlayerSummary(steamid string) (common.PlayerSummaryInfo, error)
+	InsertPlayerSummary(s common.PlayerSummaryInfo) error
+}
+
+// SteamAPI talks to the steam web api
+type SteamAPI struct {
+	Key   string
+	Cache ProfileCache
+}
 
 type AuthenticateUserTicketResponse struct {
 	Response struct {
@@ -45,10 +54,10 @@ type AuthenticateUserTicketResponse struct {
 	} `json:"response"`
 }
 
-func AuthenticateUserTicket(ticket string) (common.UserTicketInfo, error) {
+func (s *SteamAPI) AuthenticateUserTicket(ticket string) (common.UserTicketInfo, error) {
 	v := make(url.Values)
 
-	v.Set("key", SteamAPIKey)
+	v.Set("key", s.Key)
 	v.Set("appid", "4000") // garry's mod
 	v.Set("ticket", ticket)
 
@@ -79,12 +88,12 @@ type GetPlayerSummariesResponse struct {
 	} `json:"response"`
 }
 
-func GetPlayerSummaries(steamids ...string) ([]common.PlayerSummaryInfo, error) {
+func (s *SteamAPI) GetPlayerSummaries(steamids ...string) ([]common.PlayerSummaryInfo, error) {
 	var summaries []common.PlayerSummaryInfo
 
 	// fetch from cache
 	for i, steamid := range steamids {
-		summary, err := db.FetchPlayerSummary(steamid)
+		summary, err := s.Cache.FetchPlayerSummary(steamid)
 		if err != nil {
 			if !errors.Is(err, sql.ErrNoRows) {
 				return nil, fmt.Errorf("failed to fetch player summary: %s", err)
@@ -113,7 +122,7 @@ func GetPlayerSummaries(steamids ...string) ([]common.PlayerSummaryInfo, error)
 
 	v := make(url.Values)
 
-	v.Set("key", SteamAPIKey)
+	v.Set("key", s.Key)
 	v.Set("steamids", buf.String())
 
 	r, err := http.Get(fmt.Sprintf("https://api.steampowered.com/ISteamUser/GetPlayerSummaries/v2/?%s", v.Encode()))
@@ -135,7 +144,7 @@ func GetPlayerSummaries(steamids ...string) ([]common.PlayerSummaryInfo, error)
 
 	for _, summary := range rd.Response.Players {
 		// insert into cache
-		err = db.InsertPlayerSummary(summary)
+		err = s.Cache.InsertPlayerSummary(summary)
 		if err != nil {
 			return nil, fmt.Errorf("failed to insert player summary: %s", err)
 		}